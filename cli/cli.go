@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"automation-chain/config"
+)
+
+// command is a CLI subcommand
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(args []string) error
+}
+
+// commands lists the available subcommands
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "run -pipeline <name>", "Execute a pipeline once", runCommand},
+		{"resume", "resume <run-id>", "Resume a failed run from its first failed node", resumeCommand},
+		{"serve", "serve [-dir config/pipelines]", "Run every pipeline on its schedule (alias: daemon)", serveCommand},
+		{"validate", "validate [<pipeline>...]", "Check pipeline and credential files", validateCommand},
+		{"schema", "schema [pipeline|credentials] [-o file]", "Export the JSON Schema of pipeline or credential files", schemaCommand},
+		{"nodes", "nodes list | describe <type> | docs", "List, describe and document the registered node types", nodesCommand},
+		{"creds", "creds check | encrypt | decrypt | edit", "Check the credentials; encrypt, decrypt or edit the credentials file", credsCommand},
+		{"usage", "usage [-by channel] [-month YYYY-MM]", "Report the tokens and the cost of model calls per month", usageCommand},
+	}
+}
+
+// Run executes the command line and returns the process exit code.
+// Arguments starting with a flag, e.g. "-pipeline telegram", run a pipeline
+// as before subcommands existed.
+//
+// The secrets of the loaded credentials are masked in the log and in the
+// reported errors.
+func Run(args []string) int {
+	log.SetOutput(config.Secrets().Writer(os.Stderr))
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && !isHelp(args[0]) {
+		return exitCode(runCommand(args))
+	}
+
+	name := args[0]
+	if name == "daemon" {
+		name = "serve"
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return exitCode(cmd.run(args[1:]))
+		}
+	}
+
+	if isHelp(name) || name == "help" {
+		printUsage()
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+	printUsage()
+	return 2
+}
+
+// printUsage prints the list of commands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: automation-chain <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-40s %s\n", cmd.usage, cmd.description)
+	}
+}
+
+// isHelp reports whether an argument asks for help
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// exitCode reports a command error and converts it to an exit code. Asking
+// a command for help is not an error: its flag set printed the usage.
+func exitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", config.Secrets().Error(err))
+		return 1
+	}
+	return 0
+}
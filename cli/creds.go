@@ -0,0 +1,236 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"automation-chain/config"
+)
+
+// credsUsage lists the subcommands of creds
+const credsUsage = "usage: creds check [service[/name]] | creds encrypt [-in file] [-out file] [-remove] | creds decrypt [-in file] [-out file] | creds edit [file]"
+
+// credsCommand checks the credentials and manages encrypted credentials
+// files. The passphrase comes from the environment (see config.Passphrase).
+func credsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(credsUsage)
+	}
+
+	switch args[0] {
+	case "check":
+		return checkCredentials(args[1:])
+	case "encrypt":
+		return encryptCredentials(args[1:])
+	case "decrypt":
+		return decryptCredentials(args[1:])
+	case "edit":
+		return editCredentials(args[1:])
+	default:
+		if isHelp(args[0]) {
+			fmt.Fprintln(os.Stderr, credsUsage)
+			return nil
+		}
+		return fmt.Errorf(credsUsage)
+	}
+}
+
+// encryptCredentials encrypts a plain credentials file
+func encryptCredentials(args []string) error {
+	flags := flag.NewFlagSet("creds encrypt", flag.ContinueOnError)
+	in := flags.String("in", config.DefaultCredentialsFile, "Plain credentials file")
+	out := flags.String("out", "", "Encrypted file to write (default: the input file with "+config.EncryptedSuffix+")")
+	remove := flags.Bool("remove", false, "Remove the plain file once encrypted")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = *in + config.EncryptedSuffix
+	}
+
+	plaintext, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	if config.IsEncrypted(plaintext) {
+		return fmt.Errorf("%s is already encrypted", *in)
+	}
+	if !json.Valid(plaintext) {
+		return fmt.Errorf("%s is not valid JSON", *in)
+	}
+
+	if err := writeEncrypted(*out, plaintext); err != nil {
+		return err
+	}
+	fmt.Printf("🔒 Encrypted %s to %s\n", *in, *out)
+
+	if *remove {
+		return os.Remove(*in)
+	}
+	return nil
+}
+
+// decryptCredentials decrypts an encrypted credentials file
+func decryptCredentials(args []string) error {
+	flags := flag.NewFlagSet("creds decrypt", flag.ContinueOnError)
+	in := flags.String("in", config.DefaultCredentialsFile+config.EncryptedSuffix, "Encrypted credentials file")
+	out := flags.String("out", "", "Plain file to write (default: standard output)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	plaintext, err := readEncrypted(*in)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(plaintext)
+		return err
+	}
+	return writeFileAtomic(*out, plaintext)
+}
+
+// editCredentials opens the decrypted content of an encrypted credentials
+// file in $VISUAL or $EDITOR, and encrypts it again once saved. The
+// decrypted copy is a private temporary file, removed afterwards.
+func editCredentials(args []string) error {
+	file := config.DefaultCredentialsFile + config.EncryptedSuffix
+	if len(args) > 1 {
+		return fmt.Errorf("usage: creds edit [file]")
+	}
+	if len(args) == 1 {
+		file = args[0]
+	}
+
+	plaintext, err := readEncrypted(file)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "credentials-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(plaintext)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	edited := plaintext
+	for {
+		if err := runEditor(tmp.Name()); err != nil {
+			return err
+		}
+		if edited, err = os.ReadFile(tmp.Name()); err != nil {
+			return err
+		}
+		if json.Valid(edited) {
+			break
+		}
+		if !confirm("The credentials are not valid JSON. Edit again? (otherwise the changes are discarded)") {
+			return fmt.Errorf("changes to %s discarded", file)
+		}
+	}
+
+	if bytes.Equal(edited, plaintext) {
+		fmt.Printf("No changes to %s\n", file)
+		return nil
+	}
+	if err := writeEncrypted(file, edited); err != nil {
+		return err
+	}
+	fmt.Printf("🔒 Saved %s\n", file)
+	return nil
+}
+
+// readEncrypted reads and decrypts an encrypted credentials file
+func readEncrypted(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !config.IsEncrypted(data) {
+		return nil, fmt.Errorf("%s is not an encrypted credentials file", path)
+	}
+
+	passphrase, err := config.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := config.Decrypt(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	return plaintext, nil
+}
+
+// writeEncrypted encrypts credentials and writes them to a file
+func writeEncrypted(path string, plaintext []byte) error {
+	passphrase, err := config.Passphrase()
+	if err != nil {
+		return err
+	}
+	data, err := config.Encrypt(plaintext, passphrase)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic replaces a file with a private one, so that an
+// interrupted write doesn't leave it truncated
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// runEditor opens a file in the editor of the user, attached to the terminal
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"automation-chain/config"
+	"automation-chain/services"
+)
+
+// checkOutcome is what a health check learned about a credential
+type checkOutcome struct {
+	// Permissions describes what the credential may do
+	Permissions string
+	// Warning reports a problem that doesn't prevent using the credential
+	Warning string
+}
+
+// credentialChecks exercise the credentials of a service with a cheap API
+// call. The outcome may be set when the check fails, e.g. with the rights
+// missing to publish.
+var credentialChecks = map[string]func(ctx context.Context, values map[string]interface{}) (checkOutcome, error){
+	"anthropic": checkLLM("anthropic"),
+	"ollama":    checkLLM("ollama"),
+	"openai":    checkLLM("openai"),
+	"telegram":  checkTelegram,
+}
+
+// checkCredentials exercises every credential, or those of a service or
+// the one named service/name, and prints a table of the outcomes
+func checkCredentials(args []string) error {
+	flags := flag.NewFlagSet("creds check", flag.ContinueOnError)
+	credentialsFile := credentialsFlag(flags)
+	timeout := flags.Duration("timeout", 10*time.Second, "Timeout of each check")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("usage: creds check [service[/name]]")
+	}
+
+	credentials, err := config.LoadCredentialsManager(*credentialsFile)
+	if err != nil {
+		return err
+	}
+	targets, err := checkTargets(credentials, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CREDENTIAL\tSTATUS\tLATENCY\tPERMISSIONS")
+	var problems []string
+	failed := 0
+	for _, target := range targets {
+		service, name, _ := strings.Cut(target, "/")
+		outcome, latency, err := checkCredential(credentials, service, name, *timeout)
+
+		status, latencyText := "ok", fmt.Sprintf("%dms", latency.Milliseconds())
+		switch {
+		case err != nil:
+			failed++
+			status = "failed"
+			if class := services.ClassifyError(err); class != services.ErrorClassUnknown {
+				status += " (" + string(class) + ")"
+			}
+			problems = append(problems, fmt.Sprintf("%s: %v", target, config.Secrets().Error(err)))
+		case outcome.Warning != "":
+			status = "warning"
+			problems = append(problems, fmt.Sprintf("%s: %s", target, outcome.Warning))
+		}
+		if latency == 0 {
+			latencyText = "-"
+		}
+		permissions := outcome.Permissions
+		if permissions == "" {
+			permissions = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", target, status, latencyText, permissions)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		fmt.Println()
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d credentials failed", failed, len(targets))
+	}
+	return nil
+}
+
+// checkTargets returns the service/name of the credentials to check
+func checkTargets(credentials *config.CredentialsManager, filter string) ([]string, error) {
+	service, _, single := strings.Cut(filter, "/")
+	if single {
+		if _, exists := credentialChecks[service]; !exists {
+			return nil, fmt.Errorf("no health check for %s credentials", service)
+		}
+		return []string{filter}, nil
+	}
+
+	var checked []string
+	if service != "" {
+		if _, exists := credentialChecks[service]; !exists {
+			return nil, fmt.Errorf("no health check for %s credentials", service)
+		}
+		checked = []string{service}
+	} else {
+		for service := range credentialChecks {
+			checked = append(checked, service)
+		}
+		sort.Strings(checked)
+	}
+
+	var targets []string
+	for _, service := range checked {
+		for _, name := range credentials.Names(service) {
+			targets = append(targets, service+"/"+name)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no credentials to check")
+	}
+	return targets, nil
+}
+
+// checkCredential looks up a credential and runs the check of its service,
+// returning how long the API calls took
+func checkCredential(credentials *config.CredentialsManager, service, name string, timeout time.Duration) (checkOutcome, time.Duration, error) {
+	values, err := credentials.Credential(service, name)
+	if err != nil {
+		return checkOutcome{}, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	outcome, err := credentialChecks[service](ctx, values)
+	return outcome, time.Since(start), err
+}
+
+// checkLLM lists the models of a language model credential, with the
+// provider it names or the one of its section
+func checkLLM(section string) func(ctx context.Context, values map[string]interface{}) (checkOutcome, error) {
+	return func(ctx context.Context, values map[string]interface{}) (checkOutcome, error) {
+		name := section
+		if provider, ok := values["provider"].(string); ok && provider != "" {
+			name = provider
+		}
+		provider, err := services.NewLLMProvider(name)
+		if err != nil {
+			return checkOutcome{}, err
+		}
+		if err := provider.LoadConfig(values); err != nil {
+			return checkOutcome{}, err
+		}
+
+		check, err := provider.Check(ctx)
+		if err != nil {
+			return checkOutcome{}, err
+		}
+		return modelsOutcome(check), nil
+	}
+}
+
+// modelsOutcome describes the models a credential may use
+func modelsOutcome(check *services.ModelsCheck) checkOutcome {
+
+	outcome := checkOutcome{Permissions: fmt.Sprintf("%d models", check.Models)}
+	if check.Models == 1 {
+		outcome.Permissions = "1 model"
+	}
+	if check.ModelAvailable {
+		outcome.Permissions += ", " + check.Model + " available"
+	} else {
+		outcome.Warning = fmt.Sprintf("model %s is not available to this key", check.Model)
+	}
+	return outcome
+}
+
+// checkTelegram looks up a bot and its rights in the configured channel
+func checkTelegram(ctx context.Context, values map[string]interface{}) (checkOutcome, error) {
+	service := services.NewTelegram()
+	if err := service.LoadConfig(values); err != nil {
+		return checkOutcome{}, err
+	}
+
+	check, err := service.Check(ctx)
+	if err != nil {
+		return checkOutcome{}, err
+	}
+
+	outcome := checkOutcome{Permissions: fmt.Sprintf("%s is %s of %s", check.Bot, check.Role, check.Chat)}
+	if !check.CanPost {
+		outcome.Permissions += ", cannot post"
+		return outcome, fmt.Errorf("%s cannot post to %s", check.Bot, check.Chat)
+	}
+	outcome.Permissions += ", can post"
+	return outcome, nil
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	// Register the built-in node types
+	_ "automation-chain/nodes/all"
+	"automation-chain/nodes/base"
+	"automation-chain/nodes/docs"
+)
+
+// nodesUsage lists the subcommands of nodes
+const nodesUsage = "usage: nodes list | nodes describe <type> | nodes docs [-update file]"
+
+// nodesCommand inspects the registered node types
+func nodesCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(nodesUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return listNodeTypes()
+	case "describe":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: nodes describe <type>")
+		}
+		return describeNodeType(args[1])
+	case "docs":
+		return nodeDocs(args[1:])
+	default:
+		if isHelp(args[0]) {
+			fmt.Fprintln(os.Stderr, nodesUsage)
+			return nil
+		}
+		return fmt.Errorf(nodesUsage)
+	}
+}
+
+// listNodeTypes prints a table of the registered node types
+func listNodeTypes() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tCREDENTIALS\tDESCRIPTION")
+	for _, nodeType := range base.NodeTypes() {
+		credentials := "-"
+		if nodeType.CredentialService != "" {
+			credentials = nodeType.CredentialService
+			if nodeType.DefaultCredential != "" {
+				credentials += " (default: " + nodeType.DefaultCredential + ")"
+			}
+			if nodeType.CredentialServiceParam != "" {
+				credentials += ", section set by " + nodeType.CredentialServiceParam
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", nodeType.Type, credentials, nodeType.Description)
+	}
+
+	return w.Flush()
+}
+
+// describeNodeType prints the metadata of a node type as JSON
+func describeNodeType(name string) error {
+	nodeType, exists := base.LookupNodeType(name)
+	if !exists {
+		return fmt.Errorf("unknown node type: %s", name)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(nodeType)
+}
+
+// nodeDocs prints the Markdown reference of the registered node types, or
+// updates the generated reference of a document
+func nodeDocs(args []string) error {
+	flags := flag.NewFlagSet("nodes docs", flag.ContinueOnError)
+	update := flags.String("update", "", "Markdown file whose generated node reference is replaced")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *update != "" {
+		if err := docs.UpdateFile(*update, base.NodeTypes()); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Updated the node reference of %s\n", *update)
+		return nil
+	}
+
+	fmt.Print(docs.Reference(base.NodeTypes()))
+	return nil
+}
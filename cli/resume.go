@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"automation-chain/config"
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+// resumeCommand executes again the nodes of a stored run that did not
+// succeed, reusing the outputs of the nodes that did
+func resumeCommand(args []string) error {
+	flags := flag.NewFlagSet("resume", flag.ContinueOnError)
+	runsDir := flags.String("runs-dir", pipelinebase.DefaultRunsDir, "Directory where run state is checkpointed")
+	credentialsFile := credentialsFlag(flags)
+	pricesFile := pricesFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("run ID required")
+	}
+
+	store := pipelinebase.NewRunStore(*runsDir)
+	run, err := store.Load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if run.Config == "" {
+		return fmt.Errorf("run %s doesn't record the configuration of pipeline %s", run.ID, run.Pipeline)
+	}
+
+	log.Printf("🔁 Resuming run %s of pipeline: %s", run.ID, run.Pipeline)
+
+	pipelineConfig, err := pipelinebase.LoadPipelineConfig(run.Config)
+	if err != nil {
+		return err
+	}
+
+	if err := loadPrices(*pricesFile); err != nil {
+		return err
+	}
+
+	credentials, err := config.LoadCredentialsManager(*credentialsFile)
+	if err != nil {
+		return err
+	}
+
+	pipeline, err := buildPipeline(pipelineConfig, credentials, store)
+	if err != nil {
+		return err
+	}
+
+	result, err := pipeline.Resume(context.Background(), run)
+	if err := reportResult(pipelineConfig, result, err); err != nil {
+		return fmt.Errorf("pipeline execution failed: %w", err)
+	}
+
+	log.Println("✅ Pipeline completed successfully")
+	return nil
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/services"
+)
+
+// runCommand executes a single pipeline once
+func runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	pipelineName := flags.String("pipeline", "", "Pipeline to execute")
+	runsDir := flags.String("runs-dir", pipelinebase.DefaultRunsDir, "Directory where run state is checkpointed")
+	dryRun := flags.Bool("dry-run", false, "Render and validate messages without publishing them")
+	credentialsFile := credentialsFlag(flags)
+	pricesFile := pricesFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Allow the pipeline name as a positional argument too
+	if *pipelineName == "" && flags.NArg() > 0 {
+		*pipelineName = flags.Arg(0)
+	}
+	if *pipelineName == "" {
+		return fmt.Errorf("pipeline name required")
+	}
+
+	log.Printf("🚀 Executing pipeline: %s", *pipelineName)
+
+	pipelineConfig, err := pipelinebase.LoadPipelineConfig(pipelinebase.PipelineConfigPath(*pipelineName))
+	if err != nil {
+		return err
+	}
+
+	if err := loadPrices(*pricesFile); err != nil {
+		return err
+	}
+
+	credentials, err := config.LoadCredentialsManager(*credentialsFile)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if *dryRun {
+		ctx = nodesbase.WithDryRun(ctx)
+	}
+
+	store := pipelinebase.NewRunStore(*runsDir)
+	if err := executePipeline(ctx, pipelineConfig, credentials, store); err != nil {
+		return fmt.Errorf("pipeline execution failed: %w", err)
+	}
+
+	log.Println("✅ Pipeline completed successfully")
+	return nil
+}
+
+// executePipeline builds and runs a pipeline from its configuration,
+// checkpointing the run to store
+func executePipeline(ctx context.Context, pipelineConfig *pipelinebase.PipelineConfig, credentials *config.CredentialsManager, store *pipelinebase.RunStore) error {
+	pipeline, err := buildPipeline(pipelineConfig, credentials, store)
+	if err != nil {
+		return err
+	}
+
+	result, err := pipeline.Execute(ctx)
+	return reportResult(pipelineConfig, result, err)
+}
+
+// buildPipeline builds a pipeline from its configuration
+func buildPipeline(pipelineConfig *pipelinebase.PipelineConfig, credentials *config.CredentialsManager, store *pipelinebase.RunStore) (*pipelinebase.Pipeline, error) {
+	builder := pipelinebase.NewPipelineBuilder(credentials)
+	pipeline, err := builder.BuildPipelineFromConfig(pipelineConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline.SetRunStore(store)
+	return pipeline, nil
+}
+
+// reportResult logs the outcome of every node of a run
+func reportResult(pipelineConfig *pipelinebase.PipelineConfig, result *pipelinebase.Result, err error) error {
+	if result == nil {
+		return err
+	}
+
+	log.Printf("Nodes succeeded: %v, failed: %v, skipped: %v", result.Succeeded(), result.Failed(), result.Skipped())
+	if usage := result.TotalUsage(); usage.TotalTokens > 0 {
+		log.Printf("Tokens: %d (prompt %d, completion %d), cost: %s", usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens, formatCost(usage))
+	}
+	if result.RunID != "" {
+		log.Printf("Run ID: %s", result.RunID)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, nodeDef := range pipelineConfig.Nodes {
+		if output, exists := result.Outputs[nodeDef.ID]; exists {
+			log.Printf("Output of %s: %v", nodeDef.ID, output)
+		}
+	}
+
+	return nil
+}
+
+// credentialsFlag defines the -credentials flag of the commands building
+// pipelines
+func credentialsFlag(flags *flag.FlagSet) *string {
+	return flags.String("credentials", config.DefaultCredentialsFile, "Credentials file, layered under the "+config.EnvPrefix+"* environment variables")
+}
+
+// pricesFlag defines the -prices flag of the commands running pipelines
+func pricesFlag(flags *flag.FlagSet) *string {
+	return flags.String("prices", services.DefaultPricesFile, "Price table of the models, layered over the built-in prices")
+}
+
+// loadPrices sets the price table model calls are priced with
+func loadPrices(path string) error {
+	prices, err := services.LoadPrices(path)
+	if err != nil {
+		return err
+	}
+	services.SetPrices(prices)
+	return nil
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"automation-chain/pipelines/schema"
+)
+
+// schemaCommand exports the JSON Schema of pipeline or credentials files,
+// e.g. for editor autocompletion
+func schemaCommand(args []string) error {
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	output := flags.String("o", "", "File to write the schema to (default: standard output)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	kind := "pipeline"
+	if flags.NArg() > 0 {
+		kind = flags.Arg(0)
+	}
+
+	var s *schema.Schema
+	switch kind {
+	case "pipeline":
+		s = schema.Pipeline()
+	case "credentials":
+		s = schema.Credentials()
+	default:
+		return fmt.Errorf("unknown schema %s (expected pipeline or credentials)", kind)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0o644)
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/pipelines/scheduler"
+)
+
+// serveCommand runs every configured pipeline on its cron schedule until
+// SIGINT or SIGTERM is received
+func serveCommand(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	dir := flags.String("dir", pipelinebase.DefaultPipelinesDir, "Directory containing pipeline configurations")
+	runsDir := flags.String("runs-dir", pipelinebase.DefaultRunsDir, "Directory where run state is checkpointed")
+	dryRun := flags.Bool("dry-run", false, "Render and validate messages without publishing them")
+	gracePeriod := flags.Duration("grace-period", time.Minute, "Time to let running pipelines finish on shutdown")
+	credentialsFile := credentialsFlag(flags)
+	pricesFile := pricesFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	configs, err := pipelinebase.LoadPipelineConfigs(*dir)
+	if err != nil {
+		return err
+	}
+
+	if err := loadPrices(*pricesFile); err != nil {
+		return err
+	}
+
+	credentials, err := config.LoadCredentialsManager(*credentialsFile)
+	if err != nil {
+		return err
+	}
+
+	store := pipelinebase.NewRunStore(*runsDir)
+	s := scheduler.New(func(ctx context.Context, config *pipelinebase.PipelineConfig) error {
+		if *dryRun {
+			ctx = nodesbase.WithDryRun(ctx)
+		}
+		return executePipeline(ctx, config, credentials, store)
+	})
+	scheduled := 0
+	for _, config := range configs {
+		if config.Schedule == "" {
+			log.Printf("Pipeline %s has no schedule, skipping", config.Name)
+			continue
+		}
+		if err := s.Add(config); err != nil {
+			return err
+		}
+		scheduled++
+	}
+
+	if scheduled == 0 {
+		return fmt.Errorf("no scheduled pipelines found in %s", *dir)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s.Run(ctx, *gracePeriod)
+	return nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+// usageCommand reports the tokens and the cost recorded in the usage
+// ledger, per month and channel, pipeline, credential or model
+func usageCommand(args []string) error {
+	flags := flag.NewFlagSet("usage", flag.ContinueOnError)
+	runsDir := flags.String("runs-dir", pipelinebase.DefaultRunsDir, "Directory holding the usage ledger")
+	by := flags.String("by", pipelinebase.UsageByChannel, "Group by "+strings.Join(pipelinebase.UsageGroupings, ", "))
+	month := flags.String("month", "", "Only report a month, e.g. 2024-05")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("usage: usage [-by grouping] [-month YYYY-MM]")
+	}
+	if *month != "" {
+		if _, err := time.Parse("2006-01", *month); err != nil {
+			return fmt.Errorf("invalid month %q (expected e.g. 2024-05)", *month)
+		}
+	}
+
+	records, err := pipelinebase.NewRunStore(*runsDir).UsageRecords()
+	if err != nil {
+		return err
+	}
+	report, err := pipelinebase.SummarizeUsage(records, *by)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "MONTH\t%s\tCALLS\tPROMPT\tCOMPLETION\tTOTAL\tCOST\n", strings.ToUpper(*by))
+	for _, summary := range report {
+		if *month != "" && summary.Month != *month {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", summary.Month, summary.Group, summary.Calls,
+			summary.PromptTokens, summary.CompletionTokens, summary.TotalTokens, formatCost(summary.Usage))
+	}
+
+	// The total counts the calls serving several channels once
+	var total nodesbase.Usage
+	calls := 0
+	for _, record := range records {
+		if *month == "" || record.Time.Local().Format("2006-01") == *month {
+			total.Add(record.Usage)
+			calls++
+		}
+	}
+	if calls == 0 {
+		fmt.Println("No usage recorded")
+		return nil
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%d\t%d\t%d\t%d\t%s\n", calls, total.PromptTokens, total.CompletionTokens, total.TotalTokens, formatCost(total))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if total.Unpriced {
+		fmt.Println("\n* includes calls to models without a price, not counted in the cost")
+	}
+	return nil
+}
+
+// formatCost formats a cost in US dollars, marking the costs missing the
+// calls to unpriced models
+func formatCost(usage nodesbase.Usage) string {
+	cost := fmt.Sprintf("$%.4f", usage.Cost)
+	if usage.Unpriced {
+		cost += "*"
+	}
+	return cost
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"automation-chain/config"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/pipelines/schema"
+)
+
+// validateCommand checks pipeline configurations against the pipeline schema
+// and builds them, and checks the credentials file against its schema
+func validateCommand(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	credentialsFile := flags.String("credentials", config.DefaultCredentialsFile, "Credentials file to check, if it exists")
+	schemaOnly := flags.Bool("schema-only", false, "Only check the files against the schemas, without building the pipelines")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	files := make([]string, 0, flags.NArg())
+	for _, arg := range flags.Args() {
+		files = append(files, pipelineFile(arg))
+	}
+	if len(files) == 0 {
+		matches, err := filepath.Glob(filepath.Join(pipelinebase.DefaultPipelinesDir, "*.json"))
+		if err != nil {
+			return err
+		}
+		files = matches
+	}
+
+	credentials, err := config.LoadCredentialsManager(*credentialsFile)
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	pipelineSchema := schema.Pipeline()
+	for _, file := range files {
+		errs, err := schemaProblems(file, pipelineSchema)
+		if err != nil {
+			return err
+		}
+		if len(errs) == 0 && !*schemaOnly {
+			errs = buildProblems(file, credentials)
+		}
+		problems += report(file, errs)
+	}
+
+	if path := credentials.Path(); path != "" {
+		// Check the content the manager read, decrypted if need be
+		data, err := config.ReadCredentialsFile(path)
+		if err != nil {
+			return err
+		}
+		errs := schema.Validate(path, data, schema.Credentials())
+		problems += report(path, toErrors(errs))
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems found", problems)
+	}
+	return nil
+}
+
+// pipelineFile resolves a pipeline name or a file path
+func pipelineFile(arg string) string {
+	if strings.HasSuffix(arg, ".json") || strings.ContainsRune(arg, os.PathSeparator) {
+		return arg
+	}
+	return pipelinebase.PipelineConfigPath(arg)
+}
+
+// schemaProblems checks a file against a schema
+func schemaProblems(file string, s *schema.Schema) ([]error, error) {
+	errs, err := schema.ValidateFile(file, s)
+	if err != nil {
+		return nil, err
+	}
+	return toErrors(errs), nil
+}
+
+// toErrors converts schema errors to errors
+func toErrors(errs []schema.Error) []error {
+	problems := make([]error, len(errs))
+	for i, err := range errs {
+		problems[i] = err
+	}
+	return problems
+}
+
+// buildProblems builds the pipeline of a valid file, returning the problems
+// the builder finds (credentials, dependencies, data contracts)
+func buildProblems(file string, credentials *config.CredentialsManager) []error {
+	// The builder logs every step; only its verdict matters here
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	pipelineConfig, err := pipelinebase.LoadPipelineConfig(file)
+	if err == nil {
+		_, err = pipelinebase.NewPipelineBuilder(credentials).BuildPipelineFromConfig(pipelineConfig)
+	}
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *pipelinebase.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Problems
+	}
+	return []error{err}
+}
+
+// report prints the problems of a file and returns how many there are
+func report(file string, errs []error) int {
+	if len(errs) == 0 {
+		fmt.Printf("✅ %s\n", file)
+		return 0
+	}
+
+	fmt.Printf("❌ %s\n", file)
+	for _, err := range errs {
+		message := err.Error()
+		if !strings.HasPrefix(message, file+":") {
+			message = file + ": " + message
+		}
+		fmt.Printf("  %s\n", message)
+	}
+	return len(errs)
+}
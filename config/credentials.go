@@ -0,0 +1,346 @@
+// Package config manages the credentials of the services nodes use.
+//
+// Credentials are organized by service and name (e.g. the "premium" openai
+// credential) and come from layered sources, each overriding the fields of
+// the previous ones:
+//
+//  1. a JSON file, usually config/credentials.json, optionally encrypted
+//     with a passphrase (see Encrypt)
+//  2. environment variables named AUTOMATION_CHAIN__<SERVICE>__<NAME>__<FIELD>
+//  3. values set in memory with SetCredentials, e.g. by tests
+//
+// String values may reference secrets kept elsewhere with ${env:VAR} and
+// ${file:/path}, resolved every time the credential is looked up.
+//
+// The secret values of the credentials loaded or looked up are registered
+// in a shared Redactor (see Secrets), which masks them in logs, errors and
+// run records.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultCredentialsFile is the credentials file loaded by default
+const DefaultCredentialsFile = "config/credentials.json"
+
+// EnvPrefix starts the names of the environment variables holding
+// credentials. AUTOMATION_CHAIN__OPENAI__DEFAULT__API_KEY sets the api_key of
+// the default openai credential; AUTOMATION_CHAIN__OPENAI__DEFAULT alone sets
+// the shorthand value of the credential (see shorthandFields).
+const EnvPrefix = "AUTOMATION_CHAIN__"
+
+// shorthandFields are the fields a credential given as a plain string sets,
+// e.g. "default": "sk-..." for {"api_key": "sk-..."}
+var shorthandFields = map[string]string{
+	"anthropic": "api_key",
+	"openai":    "api_key",
+}
+
+// CredentialsManager holds the credentials of every service. It is safe for
+// concurrent use.
+type CredentialsManager struct {
+	mu sync.RWMutex
+	// file, env and memory are the layers, in increasing precedence
+	file   map[string]interface{}
+	env    map[string]interface{}
+	memory map[string]interface{}
+	// path is the file the file layer was loaded from
+	path string
+}
+
+// NewCredentialsManager creates a credentials manager without credentials
+func NewCredentialsManager() *CredentialsManager {
+	return &CredentialsManager{}
+}
+
+// LoadCredentialsManager creates a credentials manager reading the given
+// file, if it exists, and the environment
+func LoadCredentialsManager(path string) (*CredentialsManager, error) {
+	manager := NewCredentialsManager()
+	if err := manager.LoadCredentials(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	manager.LoadEnvironment(os.Environ())
+	return manager, nil
+}
+
+// LoadCredentials reads the file layer from a JSON file, which may be
+// encrypted (see ReadCredentialsFile)
+func (m *CredentialsManager) LoadCredentials(path string) error {
+	data, err := ReadCredentialsFile(path)
+	if err != nil {
+		return err
+	}
+
+	var credentials map[string]interface{}
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return fmt.Errorf("invalid credentials file %s: %w", path, err)
+	}
+	for service, values := range credentials {
+		if _, ok := values.(map[string]interface{}); !ok && !strings.HasPrefix(service, "$") {
+			return fmt.Errorf("invalid credentials file %s: %s must be an object of named credentials", path, service)
+		}
+	}
+
+	addSecrets(credentials)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.file = credentials
+	m.path = path
+	return nil
+}
+
+// LoadEnvironment reads the environment layer from KEY=value pairs, as
+// returned by os.Environ. Service, name and field are lowercased.
+func (m *CredentialsManager) LoadEnvironment(environ []string) {
+	credentials := make(map[string]interface{})
+	for _, variable := range environ {
+		key, value, found := strings.Cut(variable, "=")
+		if !found || !strings.HasPrefix(key, EnvPrefix) {
+			continue
+		}
+
+		parts := strings.Split(strings.ToLower(strings.TrimPrefix(key, EnvPrefix)), "__")
+		switch len(parts) {
+		case 2:
+			setCredential(credentials, parts[0], parts[1], value)
+		case 3:
+			setCredential(credentials, parts[0], parts[1], map[string]interface{}{parts[2]: value})
+		}
+	}
+
+	addSecrets(credentials)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.env = credentials
+}
+
+// SetCredentials replaces the in-memory layer, which takes precedence over
+// the file and the environment. It has the structure of the credentials
+// file: services holding named credentials.
+func (m *CredentialsManager) SetCredentials(credentials map[string]interface{}) {
+	addSecrets(credentials)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.memory = credentials
+}
+
+// Path returns the file the credentials were loaded from, if any
+func (m *CredentialsManager) Path() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.path
+}
+
+// Services returns the services with credentials, sorted
+func (m *CredentialsManager) Services() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, layer := range m.layers() {
+		for service := range layer {
+			if !strings.HasPrefix(service, "$") {
+				seen[service] = true
+			}
+		}
+	}
+	return sortedNames(seen)
+}
+
+// Names returns the names of the credentials of a service, sorted
+func (m *CredentialsManager) Names(service string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, layer := range m.layers() {
+		if named, ok := layer[service].(map[string]interface{}); ok {
+			for name := range named {
+				seen[name] = true
+			}
+		}
+	}
+	return sortedNames(seen)
+}
+
+// Credential returns the fields of a named credential of a service, merged
+// across the layers and with their references resolved. Credentials of the
+// services with a typed lookup are checked as well.
+func (m *CredentialsManager) Credential(service, name string) (map[string]interface{}, error) {
+	m.mu.RLock()
+	layers := m.layers()
+	m.mu.RUnlock()
+
+	var values map[string]interface{}
+	for _, layer := range layers {
+		named, ok := layer[service].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, exists := named[name]
+		if !exists {
+			continue
+		}
+
+		fields, err := credentialFields(service, value)
+		if err != nil {
+			return nil, fmt.Errorf("credential %s.%s: %w", service, name, err)
+		}
+		if values == nil {
+			values = make(map[string]interface{}, len(fields))
+		}
+		for field, fieldValue := range fields {
+			values[field] = fieldValue
+		}
+	}
+	if values == nil {
+		return nil, &NotFoundError{Service: service, Name: name}
+	}
+
+	resolved, err := resolve(values)
+	if err != nil {
+		return nil, fmt.Errorf("credential %s.%s: %w", service, name, err)
+	}
+	values = resolved.(map[string]interface{})
+	// References resolve to secrets the layers don't hold
+	addSecrets(values)
+
+	if check, exists := checks[service]; exists {
+		if err := check(values); err != nil {
+			return nil, fmt.Errorf("credential %s.%s: %w", service, name, err)
+		}
+	}
+	return values, nil
+}
+
+// layers returns the layers in increasing precedence. The caller must hold
+// the lock.
+func (m *CredentialsManager) layers() []map[string]interface{} {
+	return []map[string]interface{}{m.file, m.env, m.memory}
+}
+
+// NotFoundError reports a credential that none of the layers defines
+type NotFoundError struct {
+	Service string
+	Name    string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("credential %q not found in %s credentials", e.Name, e.Service)
+}
+
+// credentialFields returns the fields of a credential, expanding the string
+// shorthand of the services that have one
+func credentialFields(service string, value interface{}) (map[string]interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case string:
+		if field, exists := shorthandFields[service]; exists {
+			return map[string]interface{}{field: v}, nil
+		}
+		return nil, fmt.Errorf("%s credentials must be objects", service)
+	default:
+		return nil, fmt.Errorf("expected an object, got %T", value)
+	}
+}
+
+// setCredential merges the fields of a credential into a layer
+func setCredential(layer map[string]interface{}, service, name string, value interface{}) {
+	named, ok := layer[service].(map[string]interface{})
+	if !ok {
+		named = make(map[string]interface{})
+		layer[service] = named
+	}
+
+	existing, isMap := named[name].(map[string]interface{})
+	fields, isFields := value.(map[string]interface{})
+	if !isMap || !isFields {
+		named[name] = value
+		return
+	}
+	for field, fieldValue := range fields {
+		existing[field] = fieldValue
+	}
+}
+
+// referencePattern matches ${env:VAR} and ${file:/path} references
+var referencePattern = regexp.MustCompile(`\$\{(env|file):([^}]+)\}`)
+
+// resolve replaces the references of every string in a value, returning a
+// copy
+func resolve(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return resolveString(v)
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			r, err := resolve(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			resolved[key] = r
+		}
+		return resolved, nil
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			r, err := resolve(item)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	default:
+		return value, nil
+	}
+}
+
+// resolveString replaces the references of a string. A file reference is
+// replaced by the content of the file, without its trailing newline.
+func resolveString(s string) (string, error) {
+	var err error
+	resolved := referencePattern.ReplaceAllStringFunc(s, func(reference string) string {
+		match := referencePattern.FindStringSubmatch(reference)
+		kind, target := match[1], strings.TrimSpace(match[2])
+
+		switch kind {
+		case "env":
+			value, exists := os.LookupEnv(target)
+			if !exists && err == nil {
+				err = fmt.Errorf("environment variable %s is not set", target)
+			}
+			return value
+		default:
+			data, readErr := os.ReadFile(target)
+			if readErr != nil && err == nil {
+				err = fmt.Errorf("failed to read secret file: %w", readErr)
+			}
+			return strings.TrimRight(string(data), "\r\n")
+		}
+	})
+	return resolved, err
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Environment variables providing the passphrase of encrypted credentials
+// files. PassphraseEnv holds the passphrase itself; KeyFileEnv the path of a
+// file holding it, which takes precedence.
+const (
+	PassphraseEnv = "AUTOMATION_CHAIN_CREDENTIALS_PASSPHRASE"
+	KeyFileEnv    = "AUTOMATION_CHAIN_CREDENTIALS_KEY_FILE"
+)
+
+// EncryptedSuffix is appended to the name of a credentials file to get the
+// name of its encrypted variant, e.g. config/credentials.json.enc
+const EncryptedSuffix = ".enc"
+
+// encryptionFormat identifies encrypted credentials files and the version
+// of their format
+const encryptionFormat = "automation-chain/credentials/v1"
+
+// scrypt parameters of the key derivation (the recommended interactive ones)
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// ErrWrongPassphrase is returned when decrypting with the wrong passphrase,
+// or a file that was modified after being encrypted
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted file")
+
+// encryptedFile is the JSON envelope of an encrypted credentials file. The
+// plaintext is sealed with AES-256-GCM under a key derived from the
+// passphrase with scrypt.
+type encryptedFile struct {
+	Format     string `json:"format"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// IsEncrypted reports whether data is an encrypted credentials file
+func IsEncrypted(data []byte) bool {
+	var envelope struct {
+		Format string `json:"format"`
+	}
+	return json.Unmarshal(data, &envelope) == nil && envelope.Format == encryptionFormat
+}
+
+// Encrypt encrypts the content of a credentials file with a passphrase
+func Encrypt(plaintext, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+
+	envelope := encryptedFile{Format: encryptionFormat, KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP}
+	envelope.Salt = make([]byte, 16)
+	if _, err := rand.Read(envelope.Salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := envelope.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	envelope.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(envelope.Nonce); err != nil {
+		return nil, err
+	}
+	envelope.Ciphertext = aead.Seal(nil, envelope.Nonce, plaintext, []byte(encryptionFormat))
+
+	data, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Decrypt decrypts an encrypted credentials file with a passphrase
+func Decrypt(data, passphrase []byte) ([]byte, error) {
+	var envelope encryptedFile
+	if err := json.Unmarshal(data, &envelope); err != nil || envelope.Format != encryptionFormat {
+		return nil, fmt.Errorf("not an encrypted credentials file")
+	}
+	if envelope.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation %q", envelope.KDF)
+	}
+	// Files are always encrypted with the built-in parameters; others could
+	// make the key derivation take gigabytes of memory or minutes
+	if envelope.N != scryptN || envelope.R != scryptR || envelope.P != scryptP {
+		return nil, fmt.Errorf("unsupported scrypt parameters n=%d, r=%d, p=%d", envelope.N, envelope.R, envelope.P)
+	}
+
+	aead, err := envelope.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, []byte(encryptionFormat))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// cipher derives the key of a file from the passphrase
+func (e *encryptedFile) cipher(passphrase []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, e.Salt, e.N, e.R, e.P, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive the key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Passphrase returns the passphrase of encrypted credentials files, read
+// from the key file named by KeyFileEnv or from PassphraseEnv
+func Passphrase() ([]byte, error) {
+	if path := os.Getenv(KeyFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the key file: %w", err)
+		}
+		if key := bytes.TrimRight(data, "\r\n"); len(key) > 0 {
+			return key, nil
+		}
+		return nil, fmt.Errorf("key file %s is empty", path)
+	}
+
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	return nil, fmt.Errorf("set %s or %s to the passphrase of encrypted credentials", PassphraseEnv, KeyFileEnv)
+}
+
+// ReadCredentialsFile reads a credentials file, decrypting it with
+// Passphrase when it is encrypted. When path doesn't exist, its encrypted
+// variant (path + EncryptedSuffix) is read instead, if there is one.
+func ReadCredentialsFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !strings.HasSuffix(path, EncryptedSuffix) {
+		if encrypted, encErr := os.ReadFile(path + EncryptedSuffix); encErr == nil {
+			path, data, err = path+EncryptedSuffix, encrypted, nil
+		}
+	}
+	if err != nil || !IsEncrypted(data) {
+		return data, err
+	}
+
+	passphrase, err := Passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("credentials file %s is encrypted: %w", path, err)
+	}
+	plaintext, err := Decrypt(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	return plaintext, nil
+}
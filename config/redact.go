@@ -0,0 +1,181 @@
+package config
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in redacted text
+const Redacted = "[REDACTED]"
+
+// minSecretLength is the length under which credential values are not
+// considered secrets, so that masking them doesn't garble unrelated text
+const minSecretLength = 6
+
+// publicFields are the credential fields that are not secrets
+var publicFields = map[string]bool{
+	"api_type":    true,
+	"api_version": true,
+	"base_url":    true,
+	"budgets":     true,
+	"channel_id":  true,
+	"deployment":  true,
+	"model":       true,
+	"provider":    true,
+	"timeout":     true,
+}
+
+// secretPatterns match well-known secrets, masked even when they don't come
+// from the credentials (e.g. a token given on the command line)
+var secretPatterns = []*regexp.Regexp{
+	// Telegram bot tokens, e.g. in https://api.telegram.org/bot<token>/getMe
+	regexp.MustCompile(`[0-9]{6,}:[A-Za-z0-9_-]{30,}`),
+	// OpenAI API keys
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),
+}
+
+// Redactor masks secret values in text. It is safe for concurrent use.
+type Redactor struct {
+	mu sync.RWMutex
+	// secrets are sorted longest first, so that a secret containing another
+	// one is masked whole
+	secrets []string
+}
+
+// NewRedactor creates a redactor masking only the well-known secrets
+func NewRedactor() *Redactor {
+	return &Redactor{}
+}
+
+// Add registers secret values to mask. Values shorter than a few characters
+// are ignored.
+func (r *Redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, value := range values {
+		if len(value) < minSecretLength || containsString(r.secrets, value) {
+			continue
+		}
+		r.secrets = append(r.secrets, value)
+	}
+	sort.SliceStable(r.secrets, func(i, j int) bool {
+		return len(r.secrets[i]) > len(r.secrets[j])
+	})
+}
+
+// Redact masks the registered and well-known secrets of a text
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	r.mu.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// Writer returns a writer masking the secrets of what is written to w. Each
+// write is redacted on its own, which suits the log package writing whole
+// lines.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactingWriter{redactor: r, w: w}
+}
+
+type redactingWriter struct {
+	redactor *Redactor
+	w        io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// RedactedError is an error whose message has its secrets masked. It wraps
+// the original error.
+type RedactedError struct {
+	err     error
+	message string
+}
+
+func (e *RedactedError) Error() string {
+	return e.message
+}
+
+func (e *RedactedError) Unwrap() error {
+	return e.err
+}
+
+// Error masks the secrets of an error message, keeping the error in the
+// chain for errors.Is and errors.As
+func (r *Redactor) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := r.Redact(err.Error())
+	if message == err.Error() {
+		return err
+	}
+	return &RedactedError{err: err, message: message}
+}
+
+// secrets is the redactor the credentials managers register their secrets
+// in, used to mask logs, errors and run records
+var secrets = NewRedactor()
+
+// Secrets returns the redactor holding the secrets of every credential
+// loaded so far
+func Secrets() *Redactor {
+	return secrets
+}
+
+// Redact masks the secrets of every credential loaded so far in a text
+func Redact(s string) string {
+	return secrets.Redact(s)
+}
+
+// addSecrets registers the secret values of credentials, either a layer
+// (services holding named credentials) or the fields of one credential
+func addSecrets(value interface{}) {
+	var values []string
+	collectSecrets(value, "", &values)
+	secrets.Add(values...)
+}
+
+func collectSecrets(value interface{}, field string, values *[]string) {
+	if publicFields[field] {
+		return
+	}
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(field, "$") && !strings.Contains(v, "${") {
+			*values = append(*values, v)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			collectSecrets(item, key, values)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectSecrets(item, field, values)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
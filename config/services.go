@@ -0,0 +1,54 @@
+package config
+
+import "automation-chain/services"
+
+// LLMCredential is a credential of a language model provider
+type LLMCredential = services.LLMCredential
+
+// OpenAICredential is an OpenAI API credential
+type OpenAICredential = services.LLMCredential
+
+// TelegramCredential is a Telegram bot and the channel it publishes to
+type TelegramCredential = services.TelegramCredential
+
+// checks validate the credentials of the services with a typed lookup, with
+// the decoders the services load them with
+var checks = map[string]func(values map[string]interface{}) error{
+	"telegram": func(values map[string]interface{}) error {
+		_, err := services.DecodeTelegramCredential(values)
+		return err
+	},
+}
+
+func init() {
+	for _, service := range services.LLMProviders() {
+		service := service
+		checks[service] = func(values map[string]interface{}) error {
+			_, err := services.DecodeLLMCredential(service, values)
+			return err
+		}
+	}
+}
+
+// LLM returns a named credential of a language model provider section
+func (m *CredentialsManager) LLM(service, name string) (LLMCredential, error) {
+	values, err := m.Credential(service, name)
+	if err != nil {
+		return LLMCredential{}, err
+	}
+	return services.DecodeLLMCredential(service, values)
+}
+
+// OpenAI returns a named OpenAI credential
+func (m *CredentialsManager) OpenAI(name string) (OpenAICredential, error) {
+	return m.LLM(services.ProviderOpenAI, name)
+}
+
+// Telegram returns a named Telegram credential
+func (m *CredentialsManager) Telegram(name string) (TelegramCredential, error) {
+	values, err := m.Credential("telegram", name)
+	if err != nil {
+		return TelegramCredential{}, err
+	}
+	return services.DecodeTelegramCredential(values)
+}
@@ -3,6 +3,8 @@ module automation-chain
 go 1.21
 
 require (
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sashabaranov/go-openai v1.17.9
+	golang.org/x/crypto v0.17.0
 	gopkg.in/telebot.v3 v3.2.1
 )
@@ -0,0 +1,163 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+
+	"automation-chain/nodes/base"
+	"automation-chain/services"
+)
+
+// defaultFallbackOn are the error classes that move on to the next fallback
+// when a node doesn't list any, the ones retries default to
+var defaultFallbackOn = []string{
+	string(services.ErrorClassRateLimit),
+	string(services.ErrorClassNetwork),
+	string(services.ErrorClassServer),
+	string(services.ErrorClassTimeout),
+}
+
+// generationTarget is a provider, a credential and a model text can be
+// generated with
+type generationTarget struct {
+	provider services.LLMProvider
+	// credential is the service/name of the credential
+	credential string
+	// model is empty for the default model of the credential
+	model string
+}
+
+// modelName returns the model the target generates with
+func (t generationTarget) modelName() string {
+	if t.model != "" {
+		return t.model
+	}
+	return t.provider.GetModel()
+}
+
+// newProvider creates the provider of a credential of a credentials section;
+// the credential may name its own provider
+func newProvider(section string, credential map[string]interface{}) (services.LLMProvider, error) {
+	providerName := section
+	if name, ok := credential["provider"].(string); ok && name != "" {
+		providerName = name
+	}
+
+	provider, err := services.NewLLMProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if credential != nil {
+		if err := provider.LoadConfig(credential); err != nil {
+			return nil, fmt.Errorf("failed to load %s config: %w", providerName, err)
+		}
+	}
+	return provider, nil
+}
+
+// fallbackFields reads a fallback: an object with an optional credential,
+// provider and model
+func fallbackFields(item interface{}) (credential, provider, model string, err error) {
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return "", "", "", fmt.Errorf("must be an object with a credential and a model")
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make(map[string]string, len(fields))
+	for _, key := range keys {
+		switch key {
+		case "credential", "provider", "model":
+		default:
+			return "", "", "", fmt.Errorf("unknown field %q (expected credential, provider or model)", key)
+		}
+		value, ok := fields[key].(string)
+		if !ok || value == "" {
+			return "", "", "", fmt.Errorf("%s must be a non-empty string", key)
+		}
+		values[key] = value
+	}
+
+	provider = values["provider"]
+	if provider != "" {
+		if _, err := services.NewLLMProvider(provider); err != nil {
+			return "", "", "", err
+		}
+	}
+	if values["credential"] == "" && values["model"] == "" {
+		return "", "", "", fmt.Errorf("must have a credential or a model")
+	}
+	return values["credential"], provider, values["model"], nil
+}
+
+// fallbackSection returns the credentials section of a fallback: its own
+// provider, or the one of the node
+func fallbackSection(provider string, parameters map[string]interface{}) string {
+	if provider != "" {
+		return provider
+	}
+	if section, ok := parameters["provider"].(string); ok && section != "" {
+		return section
+	}
+	return services.ProviderOpenAI
+}
+
+// fallbackCredentials returns the credentials the fallbacks of a node refer
+// to, for the builder to look them up
+func fallbackCredentials(parameters map[string]interface{}) []base.CredentialRef {
+	items, _ := parameters["fallbacks"].([]interface{})
+	var refs []base.CredentialRef
+	for _, item := range items {
+		credential, provider, _, err := fallbackFields(item)
+		if err != nil || credential == "" {
+			continue
+		}
+		refs = append(refs, base.CredentialRef{Service: fallbackSection(provider, parameters), Name: credential})
+	}
+	return refs
+}
+
+// parseFallbacks parses the fallbacks of a node. A fallback without a
+// credential uses the node's own; one without a model uses the default model
+// of its credential.
+func parseFallbacks(items []interface{}, config base.NodeConfig, section string) ([]generationTarget, []error) {
+	var targets []generationTarget
+	var problems []error
+	for i, item := range items {
+		name := fmt.Sprintf("fallbacks[%d]", i)
+		credentialName, provider, model, err := fallbackFields(item)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		targetSection := fallbackSection(provider, config.Parameters)
+		key := config.Credential
+		credential, _ := config.Parameters[section].(map[string]interface{})
+		switch {
+		case credentialName != "":
+			key = base.CredentialRef{Service: targetSection, Name: credentialName}.String()
+			var exists bool
+			if credential, exists = config.Credentials[key]; !exists {
+				problems = append(problems, fmt.Errorf("%s: credential %s not found", name, key))
+				continue
+			}
+		case targetSection != section:
+			problems = append(problems, fmt.Errorf("%s: credential is required with provider %s", name, targetSection))
+			continue
+		}
+
+		fallbackProvider, err := newProvider(targetSection, credential)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		targets = append(targets, generationTarget{provider: fallbackProvider, credential: key, model: model})
+	}
+	return targets, problems
+}
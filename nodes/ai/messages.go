@@ -0,0 +1,108 @@
+package ai
+
+import (
+	"fmt"
+
+	"automation-chain/nodes/base"
+	"automation-chain/services"
+)
+
+// messageTemplate is a chat message whose content is a template
+type messageTemplate struct {
+	role    string
+	content *base.Template
+}
+
+// parseExamples parses the few-shot examples of a node: objects with a role
+// (user or assistant) and a content template
+func parseExamples(examples []interface{}) ([]messageTemplate, []error) {
+	var templates []messageTemplate
+	var problems []error
+	for i, item := range examples {
+		name := fmt.Sprintf("examples[%d]", i)
+		role, content, err := messageFields(item)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if role == services.RoleSystem {
+			problems = append(problems, fmt.Errorf("%s: role must be user or assistant, use system_prompt for system messages", name))
+			continue
+		}
+
+		tmpl, err := base.ParseTemplate(name, content)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		templates = append(templates, messageTemplate{role: role, content: tmpl})
+	}
+	return templates, problems
+}
+
+// renderMessages renders message templates with the node input
+func renderMessages(templates []messageTemplate, input map[string]interface{}) ([]services.Message, error) {
+	messages := make([]services.Message, len(templates))
+	for i, tmpl := range templates {
+		content, err := tmpl.content.Render(input)
+		if err != nil {
+			return nil, err
+		}
+		messages[i] = services.Message{Role: tmpl.role, Content: content}
+	}
+	return messages, nil
+}
+
+// historyMessages reads a conversation history from upstream data: a list of
+// objects with a role and a content. The content is sent as is, not
+// rendered, as it is data rather than configuration.
+func historyMessages(value interface{}) ([]services.Message, error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case []services.Message:
+		return v, nil
+	case []interface{}:
+		items = v
+	case []map[string]interface{}:
+		for _, item := range v {
+			items = append(items, item)
+		}
+	default:
+		return nil, fmt.Errorf("history must be a list of messages, got %T", value)
+	}
+
+	messages := make([]services.Message, len(items))
+	for i, item := range items {
+		role, content, err := messageFields(item)
+		if err != nil {
+			return nil, fmt.Errorf("history[%d]: %w", i, err)
+		}
+		messages[i] = services.Message{Role: role, Content: content}
+	}
+	return messages, nil
+}
+
+// messageFields reads the role and content of a message object
+func messageFields(item interface{}) (string, string, error) {
+	var role, content interface{}
+	switch message := item.(type) {
+	case map[string]interface{}:
+		role, content = message["role"], message["content"]
+	case map[string]string:
+		role, content = message["role"], message["content"]
+	default:
+		return "", "", fmt.Errorf("expected an object with role and content, got %T", item)
+	}
+
+	roleStr, _ := role.(string)
+	switch roleStr {
+	case services.RoleSystem, services.RoleUser, services.RoleAssistant:
+	default:
+		return "", "", fmt.Errorf("role must be system, user or assistant, got %v", role)
+	}
+	contentStr, ok := content.(string)
+	if !ok {
+		return "", "", fmt.Errorf("content must be a string")
+	}
+	return roleStr, contentStr, nil
+}
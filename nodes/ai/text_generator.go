@@ -4,33 +4,121 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 
 	"automation-chain/nodes/base"
 	"automation-chain/services"
 )
 
-// TextGeneratorNode generates text using OpenAI
+func init() {
+	base.RegisterNodeType(base.NodeType{
+		Type:                   "text_generator",
+		Category:               "ai",
+		Description:            "Generates text using the chat models of OpenAI, Anthropic or a local server (Ollama, OpenAI-compatible)",
+		CredentialService:      "openai",
+		CredentialServiceParam: "provider",
+		DefaultCredential:      "default",
+		CredentialRefs:         fallbackCredentials,
+		InputPaths:             historyPath,
+		Parameters:             base.ParamSpecsOf(textGeneratorParams{}),
+		Outputs: []base.PortSpec{
+			{Name: "generated_text", Type: base.ParamString, Description: "Text generated by the model"},
+			{Name: "model_used", Type: base.ParamString, Description: "Model the text was generated with"},
+			{Name: "provider_used", Type: base.ParamString, Description: "Provider the text was generated with"},
+			{Name: "credential_used", Type: base.ParamString, Description: "Credential the text was generated with, as service/name; a fallback's when the node fell back"},
+			{Name: "tokens_used", Type: base.ParamInteger, Description: "Total number of tokens of the call"},
+			{Name: base.UsageKey, Type: base.ParamObject, Description: "Prompt, completion and total tokens of the call and its cost in US dollars, priced with the price table"},
+		},
+		Factory: func(config base.NodeConfig) (base.Node, error) {
+			return NewTextGeneratorNode(config)
+		},
+	})
+}
+
+// textGeneratorParams are the parameters of text_generator nodes
+type textGeneratorParams struct {
+	PromptTemplate   *base.Template `param:"prompt_template" required:"true" desc:"Prompt sent to the model, rendered with the node input"`
+	SystemPrompt     *base.Template `param:"system_prompt" desc:"System prompt with the instructions and style of every answer, rendered with the node input"`
+	Examples         []interface{}  `param:"examples" desc:"Few-shot example messages sent before the prompt, each an object with a role (user or assistant) and a content template"`
+	History          string         `param:"history" desc:"Dotted input path of a conversation history sent before the prompt, e.g. nodes.chat_reader.messages: a list of objects with a role and a content"`
+	HistoryLimit     int            `param:"history_limit" min:"1" desc:"Number of most recent history messages sent (default: all)"`
+	Provider         string         `param:"provider" enum:"anthropic,ollama,openai" desc:"Credentials section the credential is read from, whose provider generates the text unless the credential names another one (default openai)"`
+	Model            string         `param:"model" desc:"Model to use, overriding the model of the credential (default: gpt-3.5-turbo, claude-3-5-haiku-latest or llama3 depending on the provider)"`
+	Fallbacks        []interface{}  `param:"fallbacks" desc:"Credentials and models tried in order when generation fails, each an object with a credential (default: the node's), a model (default: the credential's) and a provider (default: the node's)"`
+	FallbackOn       []string       `param:"fallback_on" desc:"Error classes that move on to the next fallback (default rate_limit, network, server, timeout)"`
+	MaxTokens        int            `param:"max_tokens" min:"1" desc:"Maximum number of tokens to generate"`
+	Temperature      *float32       `param:"temperature" min:"0" max:"2" desc:"Sampling temperature"`
+	TopP             *float32       `param:"top_p" min:"0" max:"1" desc:"Nucleus sampling probability mass"`
+	FrequencyPenalty float32        `param:"frequency_penalty" min:"-2" max:"2" desc:"Penalizes tokens by how often they already appeared"`
+	PresencePenalty  float32        `param:"presence_penalty" min:"-2" max:"2" desc:"Penalizes tokens that already appeared"`
+}
+
+// TextGeneratorNode generates text using a language model provider
 type TextGeneratorNode struct {
-	openai *services.OpenAIService
-	config base.NodeConfig
+	// targets are the node's own credential and model, then its fallbacks
+	targets      []generationTarget
+	fallbackOn   []string
+	config       base.NodeConfig
+	prompt       *base.Template
+	system       *base.Template
+	examples     []messageTemplate
+	history      string
+	historyLimit int
+	options      services.GenerateOptions
 }
 
 // NewTextGeneratorNode creates a new text generator node
 func NewTextGeneratorNode(config base.NodeConfig) (*TextGeneratorNode, error) {
-	openai := services.NewOpenAI()
-
-	// Load OpenAI config from node config
-	if openaiConfig, exists := config.Parameters["openai"]; exists {
-		if openaiMap, ok := openaiConfig.(map[string]interface{}); ok {
-			if err := openai.LoadConfig(openaiMap); err != nil {
-				return nil, fmt.Errorf("failed to load OpenAI config: %w", err)
-			}
+	var params textGeneratorParams
+	if err := base.DecodeParams(config, &params); err != nil {
+		return nil, err
+	}
+	examples, problems := parseExamples(params.Examples)
+
+	// The credential is injected under its section, which the provider
+	// parameter selects
+	section := params.Provider
+	if section == "" {
+		section = services.ProviderOpenAI
+	}
+	fallbacks, fallbackProblems := parseFallbacks(params.Fallbacks, config, section)
+	problems = append(problems, fallbackProblems...)
+
+	fallbackOn := params.FallbackOn
+	if len(fallbackOn) == 0 {
+		fallbackOn = defaultFallbackOn
+	}
+	for _, class := range fallbackOn {
+		if !services.IsErrorClass(class) {
+			problems = append(problems, fmt.Errorf("unknown error class in fallback_on: %s", class))
 		}
 	}
+	if len(problems) > 0 {
+		return nil, &base.ParamsError{NodeID: config.ID, Problems: problems}
+	}
+
+	credential, _ := config.Parameters[section].(map[string]interface{})
+	provider, err := newProvider(section, credential)
+	if err != nil {
+		return nil, err
+	}
 
 	return &TextGeneratorNode{
-		openai: openai,
-		config: config,
+		targets:      append([]generationTarget{{provider: provider, credential: config.Credential, model: params.Model}}, fallbacks...),
+		fallbackOn:   fallbackOn,
+		config:       config,
+		prompt:       params.PromptTemplate,
+		system:       params.SystemPrompt,
+		examples:     examples,
+		history:      params.History,
+		historyLimit: params.HistoryLimit,
+		options: services.GenerateOptions{
+			MaxTokens:        params.MaxTokens,
+			Temperature:      params.Temperature,
+			TopP:             params.TopP,
+			FrequencyPenalty: params.FrequencyPenalty,
+			PresencePenalty:  params.PresencePenalty,
+		},
 	}, nil
 }
 
@@ -46,53 +134,172 @@ func (n *TextGeneratorNode) Config() base.NodeConfig {
 
 // Validate validates the node configuration
 func (n *TextGeneratorNode) Validate() error {
-	if !n.openai.IsReady() {
-		return fmt.Errorf("OpenAI service is not initialized")
-	}
-
-	// Validate required parameters
-	requiredParams := []string{"prompt_template"}
-	for _, param := range requiredParams {
-		if _, exists := n.config.Parameters[param]; !exists {
-			return fmt.Errorf("required parameter '%s' not found in configuration", param)
+	for _, target := range n.targets {
+		if !target.provider.IsReady() {
+			return fmt.Errorf("%s provider is not initialized", target.provider.Name())
 		}
 	}
 
 	return nil
 }
 
-// Execute generates text using OpenAI
+// Execute generates text with the node's credential and model, moving on to
+// the next fallback when generation fails with one of the fallback_on classes
 func (n *TextGeneratorNode) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
-	log.Println("Generating text with OpenAI...")
+	// Render prompt template with input data
+	prompt, err := n.prompt.Render(input)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := n.messages(input)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, target := range n.targets {
+		output, err := n.generate(ctx, target, prompt, options)
+		if err == nil {
+			return output, nil
+		}
 
-	// Get prompt template
-	promptTemplate := n.config.Parameters["prompt_template"].(string)
+		class := services.ClassifyError(err)
+		if i == len(n.targets)-1 || !slices.Contains(n.fallbackOn, string(class)) {
+			return nil, fmt.Errorf("failed to generate text: %w", err)
+		}
+		next := n.targets[i+1]
+		log.Printf("Generation with %s (%s) failed with a %s error, falling back to %s (%s): %v",
+			target.credential, target.modelName(), class, next.credential, next.modelName(), err)
+	}
+	return nil, fmt.Errorf("no model to generate text with")
+}
 
-	// Process prompt template with input data
-	prompt := processTemplate(promptTemplate, input)
+// generate generates text with a target
+func (n *TextGeneratorNode) generate(ctx context.Context, target generationTarget, prompt string, options services.GenerateOptions) (map[string]interface{}, error) {
+	options.Model = target.model
+	log.Printf("Generating text with %s (%s)...", target.provider.Name(), target.modelName())
+
+	// The budgets of the run may refuse the call or switch it to a cheaper model
+	guard := base.BudgetGuardFrom(ctx)
+	var decision base.BudgetDecision
+	if guard != nil {
+		var err error
+		if decision, err = guard.Check(ctx, n.budgetCall(target, options)); err != nil {
+			return nil, &services.ServiceError{Service: target.provider.Name(), Class: services.ErrorClassBudget, Err: err}
+		}
+		options.Model = decision.Model
+	}
 
-	// Generate text using OpenAI service
-	generatedText, err := n.openai.GenerateText(ctx, prompt)
+	completion, err := target.provider.Generate(ctx, prompt, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate text: %w", err)
+		if guard != nil {
+			guard.Release(decision)
+		}
+		return nil, err
 	}
 
-	log.Printf("Generated text: %s", generatedText)
+	log.Printf("Generated text: %s", completion.Text)
+
+	usage := n.usage(target, completion)
+	log.Printf("Used %d tokens ($%.6f)", usage.TotalTokens, usage.Cost)
+	if guard != nil {
+		guard.Spend(decision, usage)
+	}
+
+	model := options.Model
+	if model == "" {
+		model = target.modelName()
+	}
 
 	// Return the generated text for the next node
 	return map[string]interface{}{
-		"generated_text": generatedText,
-		"model_used":     n.openai.GetModel(),
+		"generated_text":  completion.Text,
+		"model_used":      model,
+		"provider_used":   target.provider.Name(),
+		"credential_used": target.credential,
+		"tokens_used":     usage.TotalTokens,
+		base.UsageKey:     usage.Output(),
 	}, nil
 }
 
-// processTemplate processes a template string with input data
-func processTemplate(template string, input map[string]interface{}) string {
-	// Simple template processing - replace {{key}} with values
-	// In a real implementation, you might want to use a proper template engine
-	result := template
+// usage prices the tokens of a completion
+func (n *TextGeneratorNode) usage(target generationTarget, completion *services.Completion) base.Usage {
+	usage := base.Usage{
+		PromptTokens:     completion.Usage.PromptTokens,
+		CompletionTokens: completion.Usage.CompletionTokens,
+		TotalTokens:      completion.Usage.TotalTokens,
+		Provider:         target.provider.Name(),
+		Model:            completion.Model,
+		Credential:       target.credential,
+	}
+
+	cost, priced := services.Prices().Cost(completion.Model, completion.Usage)
+	// Local models are free unless the price table says otherwise
+	if !priced && target.provider.Name() != services.ProviderOllama {
+		log.Printf("Warning: no price for model %s, its cost is not counted", completion.Model)
+		usage.Unpriced = true
+	}
+	usage.Cost = cost
+	return usage
+}
+
+// budgetCall describes a call for the budget guard, reserving the cost of
+// max_tokens completion tokens when set
+func (n *TextGeneratorNode) budgetCall(target generationTarget, options services.GenerateOptions) base.BudgetCall {
+	model := target.modelName()
+	call := base.BudgetCall{Credential: target.credential, Model: model}
+	if options.MaxTokens > 0 {
+		call.Reserve.TotalTokens = options.MaxTokens
+		if price, exists := services.Prices().Lookup(model); exists {
+			call.Reserve.Cost = float64(options.MaxTokens) * price.Completion / 1e6
+		}
+	}
+	return call
+}
+
+// messages returns the generation options with the system prompt, the
+// examples and the history rendered for an input
+func (n *TextGeneratorNode) messages(input map[string]interface{}) (services.GenerateOptions, error) {
+	options := n.options
+	if n.system != nil {
+		system, err := n.system.Render(input)
+		if err != nil {
+			return options, err
+		}
+		options.System = system
+	}
+
+	examples, err := renderMessages(n.examples, input)
+	if err != nil {
+		return options, err
+	}
+	options.Messages = examples
+
+	// A missing history is a conversation yet to start, or a path that
+	// names no output of its node
+	if n.history != "" {
+		value, exists := base.LookupInput(input, n.history)
+		if !exists || value == nil {
+			log.Printf("Warning: node %s: no history at %s, generating without it", n.config.ID, n.history)
+			return options, nil
+		}
+		history, err := historyMessages(value)
+		if err != nil {
+			return options, err
+		}
+		if n.historyLimit > 0 && len(history) > n.historyLimit {
+			history = history[len(history)-n.historyLimit:]
+		}
+		options.Messages = append(options.Messages, history...)
+	}
+	return options, nil
+}
 
-	// For now, just return the template as-is
-	// TODO: Implement proper template processing
-	return result
-} 
\ No newline at end of file
+// historyPath returns the input path of the history of a node, for the
+// pipeline to check it names an upstream node
+func historyPath(parameters map[string]interface{}) map[string]string {
+	if path, ok := parameters["history"].(string); ok && path != "" {
+		return map[string]string{"history": path}
+	}
+	return nil
+}
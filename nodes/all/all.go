@@ -0,0 +1,11 @@
+// Package all registers every built-in node type. Import it for its side
+// effects:
+//
+//	import _ "automation-chain/nodes/all"
+package all
+
+import (
+	// Built-in node types
+	_ "automation-chain/nodes/ai"
+	_ "automation-chain/nodes/publishers"
+)
@@ -0,0 +1,169 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Budget periods, in the local time zone
+const (
+	BudgetPerDay   = "day"
+	BudgetPerMonth = "month"
+)
+
+// Budget limits the tokens or the cost of the model calls of a credential
+// or a pipeline over a day or a month
+type Budget struct {
+	// Tokens is the maximum number of tokens of the period
+	Tokens int `json:"tokens,omitempty"`
+	// Cost is the maximum cost of the period, in US dollars
+	Cost   float64 `json:"cost,omitempty"`
+	Period string  `json:"period"`
+	// WarnAt is the fraction of the budget (e.g. 0.8) past which an alert is
+	// emitted, once per period
+	WarnAt float64 `json:"warn_at,omitempty"`
+	// Alert is the telegram credential the alert is sent with, if any;
+	// alerts are logged either way
+	Alert string `json:"alert,omitempty"`
+	// FallbackModel is used once the budget is exhausted. Without one, the
+	// calls are refused.
+	FallbackModel string `json:"fallback_model,omitempty"`
+}
+
+// Validate checks that the budget limits exactly one of tokens or cost
+func (b Budget) Validate() error {
+	switch {
+	case b.Tokens < 0 || b.Cost < 0:
+		return fmt.Errorf("budget limits must not be negative")
+	case (b.Tokens > 0) == (b.Cost > 0):
+		return fmt.Errorf("budget must limit either tokens or cost")
+	case b.Period != BudgetPerDay && b.Period != BudgetPerMonth:
+		return fmt.Errorf("budget period must be %s or %s, got %q", BudgetPerDay, BudgetPerMonth, b.Period)
+	case b.WarnAt < 0 || b.WarnAt >= 1:
+		return fmt.Errorf("budget warn_at must be a fraction between 0 and 1, got %v", b.WarnAt)
+	}
+	return nil
+}
+
+// String describes the budget, e.g. "200000 tokens/day" or "$5.00/month"
+func (b Budget) String() string {
+	if b.Tokens > 0 {
+		return fmt.Sprintf("%d tokens/%s", b.Tokens, b.Period)
+	}
+	return fmt.Sprintf("$%.2f/%s", b.Cost, b.Period)
+}
+
+// Start returns the start of the period holding t
+func (b Budget) Start(t time.Time) time.Time {
+	t = t.Local()
+	if b.Period == BudgetPerMonth {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// Limit returns the limit of the budget, in tokens or dollars
+func (b Budget) Limit() float64 {
+	if b.Tokens > 0 {
+		return float64(b.Tokens)
+	}
+	return b.Cost
+}
+
+// Used returns the part of usage the budget counts, in tokens or dollars
+func (b Budget) Used(usage Usage) float64 {
+	if b.Tokens > 0 {
+		return float64(usage.TotalTokens)
+	}
+	return usage.Cost
+}
+
+// DecodeBudgets reads the budgets field of a credential or a pipeline
+// configuration: a list of budgets
+func DecodeBudgets(value interface{}) ([]Budget, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var budgets []Budget
+	if err := json.Unmarshal(data, &budgets); err != nil {
+		return nil, fmt.Errorf("budgets must be a list of budgets: %w", err)
+	}
+	for i, budget := range budgets {
+		if err := budget.Validate(); err != nil {
+			return nil, fmt.Errorf("budgets[%d]: %w", i, err)
+		}
+	}
+	return budgets, nil
+}
+
+// BudgetCall describes a model call about to be made
+type BudgetCall struct {
+	// Credential is the service/name of the credential of the call
+	Credential string
+	Model      string
+	// Reserve is the most the call is expected to use, e.g. its max_tokens,
+	// held until the call is settled
+	Reserve Usage
+}
+
+// BudgetDecision is the outcome of a budget check
+type BudgetDecision struct {
+	// Model is the model to call, a fallback when a budget is exhausted
+	Model string
+	// Reservation identifies the reserve the check held for the call, zero
+	// when it held none
+	Reservation int
+}
+
+// BudgetError is a call refused because a budget is exhausted
+type BudgetError struct {
+	// Scope is what the budget applies to, e.g. "credential openai/premium"
+	Scope  string
+	Budget Budget
+	// Used is what the period used so far, in tokens or dollars
+	Used float64
+}
+
+func (e *BudgetError) Error() string {
+	used := fmt.Sprintf("%.0f tokens", e.Used)
+	if e.Budget.Cost > 0 {
+		used = fmt.Sprintf("$%.2f", e.Used)
+	}
+	return fmt.Sprintf("budget %s of %s would be exceeded (%s used)", e.Budget, e.Scope, used)
+}
+
+// BudgetGuard enforces the budgets of a run. Pipelines place one in the
+// context of their nodes when budgets apply, so nodes calling paid APIs
+// check every call and settle it with Spend or Release.
+type BudgetGuard interface {
+	// Check returns the model to call, or a *BudgetError when the call
+	// would exceed a budget without a fallback model. The reserve of an
+	// allowed call counts towards the budgets until it is settled, so
+	// concurrent calls can't overshoot them.
+	Check(ctx context.Context, call BudgetCall) (BudgetDecision, error)
+	// Spend settles a call with what it used
+	Spend(decision BudgetDecision, usage Usage)
+	// Release settles a call that failed
+	Release(decision BudgetDecision)
+}
+
+// budgetGuardKey is the context key of the budget guard
+type budgetGuardKey struct{}
+
+// WithBudgetGuard returns a context carrying a budget guard
+func WithBudgetGuard(ctx context.Context, guard BudgetGuard) context.Context {
+	return context.WithValue(ctx, budgetGuardKey{}, guard)
+}
+
+// BudgetGuardFrom returns the budget guard of ctx, or nil when no budget applies
+func BudgetGuardFrom(ctx context.Context) BudgetGuard {
+	guard, _ := ctx.Value(budgetGuardKey{}).(BudgetGuard)
+	return guard
+}
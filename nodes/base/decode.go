@@ -0,0 +1,304 @@
+package base
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Node parameters are decoded onto structs whose fields are tagged with the
+// name of the parameter and, optionally, its description, default, accepted
+// values and range:
+//
+//	type params struct {
+//		Prompt      *base.Template `param:"prompt_template" required:"true" desc:"Prompt sent to the model"`
+//		Temperature *float32       `param:"temperature" min:"0" max:"2"`
+//		ParseMode   string         `param:"parse_mode" enum:"Markdown,MarkdownV2,HTML"`
+//		Timeout     base.Duration  `param:"timeout" default:"30s"`
+//	}
+//
+// The type of a parameter follows from the type of its field: strings,
+// *Template, integers, floats, bools, Duration or time.Duration, []string,
+// []interface{} and map[string]interface{}. Pointer fields stay nil when the
+// parameter is not set and has no default.
+
+// ParamsError lists every invalid parameter of a node
+type ParamsError struct {
+	NodeID   string
+	Problems []error
+}
+
+// Error lists the problems, one per line
+func (e *ParamsError) Error() string {
+	if len(e.Problems) == 1 {
+		return fmt.Sprintf("node %s: %v", e.NodeID, e.Problems[0])
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "node %s: %d invalid parameters", e.NodeID, len(e.Problems))
+	for _, problem := range e.Problems {
+		fmt.Fprintf(&b, "\n  - %v", problem)
+	}
+	return b.String()
+}
+
+// Unwrap returns the problems, so errors.Is and errors.As look through them
+func (e *ParamsError) Unwrap() []error {
+	return e.Problems
+}
+
+// DecodeParams decodes the parameters of a node onto the struct target
+// points to, applying defaults and checking required parameters, types,
+// enums and ranges. It returns a *ParamsError listing every problem found.
+// Keys without a matching field, such as the credentials the builder adds,
+// are ignored.
+func DecodeParams(config NodeConfig, target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("nodes: DecodeParams target must be a pointer to a struct, got %T", target))
+	}
+	value = value.Elem()
+
+	var problems []error
+	for _, field := range paramFields(value.Type()) {
+		raw, exists := config.Parameters[field.spec.Name]
+		if !exists {
+			if field.spec.Required {
+				problems = append(problems, fmt.Errorf("required parameter %s is missing", field.spec.Name))
+			}
+			if field.spec.Default == nil {
+				continue
+			}
+			raw = field.spec.Default
+		}
+
+		if err := field.spec.Check(raw); err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if err := assign(value.Field(field.index), field.spec, raw); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ParamsError{NodeID: config.ID, Problems: problems}
+	}
+	return nil
+}
+
+// ParamSpecsOf describes the parameters of a params struct (or a pointer to
+// one), for NodeType.Parameters. It panics on invalid tags, which are
+// programming errors.
+func ParamSpecsOf(params interface{}) []ParamSpec {
+	structType := reflect.TypeOf(params)
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("nodes: ParamSpecsOf expects a struct, got %T", params))
+	}
+
+	fields := paramFields(structType)
+	specs := make([]ParamSpec, len(fields))
+	for i, field := range fields {
+		specs[i] = field.spec
+	}
+	return specs
+}
+
+// paramField is a tagged field of a params struct
+type paramField struct {
+	index int
+	spec  ParamSpec
+}
+
+var (
+	durationType = reflect.TypeOf(Duration(0))
+	stdDuration  = reflect.TypeOf(time.Duration(0))
+	templateType = reflect.TypeOf(&Template{})
+)
+
+// paramFields reads the tags of a params struct
+func paramFields(structType reflect.Type) []paramField {
+	var fields []paramField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name := field.Tag.Get("param")
+		if name == "" {
+			continue
+		}
+
+		spec := ParamSpec{
+			Name:        name,
+			Type:        paramType(field.Type),
+			Description: field.Tag.Get("desc"),
+			Required:    field.Tag.Get("required") == "true",
+		}
+		if spec.Type == "" {
+			panic(fmt.Sprintf("nodes: unsupported type %s for parameter %s of %s", field.Type, name, structType))
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			spec.Enum = strings.Split(enum, ",")
+		}
+		spec.Min = tagLimit(structType, name, field.Tag.Get("min"))
+		spec.Max = tagLimit(structType, name, field.Tag.Get("max"))
+
+		if text, exists := field.Tag.Lookup("default"); exists {
+			def, err := parseDefault(spec.Type, text)
+			if err == nil {
+				err = spec.Check(def)
+			}
+			if err != nil {
+				panic(fmt.Sprintf("nodes: invalid default for parameter %s of %s: %v", name, structType, err))
+			}
+			spec.Default = def
+		}
+
+		fields = append(fields, paramField{index: i, spec: spec})
+	}
+	return fields
+}
+
+// paramType returns the parameter type of a field type, or an empty string
+// when it is not supported
+func paramType(fieldType reflect.Type) ParamType {
+	switch fieldType {
+	case templateType:
+		return ParamTemplate
+	case durationType, stdDuration:
+		return ParamDuration
+	}
+
+	if fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		return ParamString
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return ParamInteger
+	case reflect.Float32, reflect.Float64:
+		return ParamNumber
+	case reflect.Bool:
+		return ParamBoolean
+	case reflect.Slice:
+		if elem := fieldType.Elem().Kind(); elem == reflect.String || elem == reflect.Interface {
+			return ParamArray
+		}
+	case reflect.Map:
+		if fieldType.Key().Kind() == reflect.String && fieldType.Elem().Kind() == reflect.Interface {
+			return ParamObject
+		}
+	}
+	return ""
+}
+
+// tagLimit parses a min or max tag
+func tagLimit(structType reflect.Type, name, text string) *float64 {
+	if text == "" {
+		return nil
+	}
+	limit, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		panic(fmt.Sprintf("nodes: invalid limit %q for parameter %s of %s", text, name, structType))
+	}
+	return &limit
+}
+
+// parseDefault converts a default tag to the value a configuration file
+// would hold
+func parseDefault(paramType ParamType, text string) (interface{}, error) {
+	switch paramType {
+	case ParamInteger:
+		return strconv.Atoi(text)
+	case ParamNumber:
+		return strconv.ParseFloat(text, 64)
+	case ParamBoolean:
+		return strconv.ParseBool(text)
+	case ParamString, ParamTemplate, ParamDuration:
+		return text, nil
+	default:
+		return nil, fmt.Errorf("%s parameters can't have a default", paramType)
+	}
+}
+
+// assign sets a field to a parameter value already checked against its spec
+func assign(field reflect.Value, spec ParamSpec, raw interface{}) error {
+	switch field.Type() {
+	case templateType:
+		template, err := ParseTemplate(spec.Name, raw.(string))
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(template))
+		return nil
+	case durationType, stdDuration:
+		var duration time.Duration
+		if text, ok := raw.(string); ok {
+			duration, _ = time.ParseDuration(text)
+		} else {
+			seconds, _ := numberValue(raw)
+			duration = time.Duration(seconds * float64(time.Second))
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	if field.Kind() == reflect.Pointer {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw.(string))
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		number, _ := numberValue(raw)
+		field.SetInt(int64(number))
+	case reflect.Float32, reflect.Float64:
+		number, _ := numberValue(raw)
+		field.SetFloat(number)
+	case reflect.Bool:
+		field.SetBool(raw.(bool))
+	case reflect.Slice:
+		return assignSlice(field, spec, raw)
+	case reflect.Map:
+		field.Set(reflect.ValueOf(raw))
+	}
+	return nil
+}
+
+// assignSlice sets a []string or []interface{} field
+func assignSlice(field reflect.Value, spec ParamSpec, raw interface{}) error {
+	if strs, ok := raw.([]string); ok {
+		raw = stringsToInterfaces(strs)
+	}
+	items := raw.([]interface{})
+
+	if field.Type().Elem().Kind() == reflect.Interface {
+		field.Set(reflect.ValueOf(items))
+		return nil
+	}
+
+	strs := make([]string, len(items))
+	for i, item := range items {
+		str, ok := item.(string)
+		if !ok {
+			return fmt.Errorf("%s must be an array of strings, got %s at index %d", spec.Name, describeValue(item), i)
+		}
+		strs[i] = str
+	}
+	field.Set(reflect.ValueOf(strs))
+	return nil
+}
+
+func stringsToInterfaces(strs []string) []interface{} {
+	items := make([]interface{}, len(strs))
+	for i, str := range strs {
+		items[i] = str
+	}
+	return items
+}
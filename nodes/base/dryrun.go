@@ -0,0 +1,19 @@
+package base
+
+import "context"
+
+// dryRunKey is the context key marking a dry run
+type dryRunKey struct{}
+
+// WithDryRun returns a context marking the run as a dry run. Nodes with side
+// effects, such as publishers, must then do everything but the side effect
+// and return what they would have done.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx belongs to a dry run
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
@@ -0,0 +1,44 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration that reads from JSON as a Go duration string
+// ("1.5s", "2m") or as a number of seconds
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		*d = Duration(parsed)
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	default:
+		return fmt.Errorf("invalid duration %s: expected a string or a number of seconds", string(data))
+	}
+
+	return nil
+}
+
+// MarshalJSON writes the duration as a Go duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// String returns the duration formatted as a Go duration string
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
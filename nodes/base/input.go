@@ -0,0 +1,39 @@
+package base
+
+import "strings"
+
+// NodesKey is the input key holding the outputs of every upstream node, keyed by node ID.
+// The remaining input keys are the merged outputs of the upstream nodes, those of
+// the node's direct dependencies taking precedence.
+const NodesKey = "nodes"
+
+// ErrorKey is the input key holding the details of the failure an error
+// handler node was routed: node_id, node_name, message, class and attempts
+const ErrorKey = "error"
+
+// UpstreamOutput returns the output produced by the upstream node with the given ID
+func UpstreamOutput(input map[string]interface{}, nodeID string) (map[string]interface{}, bool) {
+	nodes, ok := input[NodesKey].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	output, ok := nodes[nodeID].(map[string]interface{})
+	return output, ok
+}
+
+// LookupInput returns the input value at a dotted path of keys, such as
+// "nodes.chat_reader.messages"
+func LookupInput(input map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = input
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
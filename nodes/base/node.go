@@ -18,13 +18,44 @@ type NodeConfig struct {
 	Type       string                 `json:"type"`
 	Name       string                 `json:"name"`
 	Parameters map[string]interface{} `json:"parameters"`
+	// Credential is the service/name of the credential the builder added
+	// to the parameters, if any
+	Credential string `json:"credential,omitempty"`
+	// Credentials are the values of the credentials of
+	// NodeType.CredentialRefs, keyed by service/name
+	Credentials map[string]map[string]interface{} `json:"-"`
+}
+
+// CredentialRef names a credential of a credentials section
+type CredentialRef struct {
+	Service string
+	Name    string
+}
+
+// String returns service/name, the form of NodeConfig.Credential
+func (r CredentialRef) String() string {
+	return r.Service + "/" + r.Name
 }
 
 // NodeDefinition represents a node in pipeline configuration
 type NodeDefinition struct {
-	ID          string                 `json:"id"`
-	Type        string                 `json:"type"`
-	Name        string                 `json:"name"`
-	Credentials string                 `json:"credentials,omitempty"`
-	Config      map[string]interface{} `json:"config"`
-} 
\ No newline at end of file
+	ID           string                 `json:"id"`
+	Type         string                 `json:"type"`
+	Name         string                 `json:"name"`
+	Credentials  string                 `json:"credentials,omitempty"`
+	DependsOn    []string               `json:"depends_on,omitempty"`
+	Retry        *RetryPolicy           `json:"retry,omitempty"`
+	Timeout      Duration               `json:"timeout,omitempty"`
+	OnError      string                 `json:"on_error,omitempty"`
+	ErrorHandler string                 `json:"error_handler,omitempty"`
+	Config       map[string]interface{} `json:"config"`
+}
+
+// Error handling modes of a node (NodeDefinition.OnError). A failing node
+// either fails the whole run (the default), is recorded as failed while the
+// rest of the pipeline continues, or is routed to its ErrorHandler node.
+const (
+	OnErrorFail     = "fail"
+	OnErrorContinue = "continue"
+	OnErrorRoute    = "route"
+)
@@ -0,0 +1,254 @@
+package base
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ParamType is the type of a node parameter in configuration files
+type ParamType string
+
+const (
+	ParamString  ParamType = "string"
+	ParamInteger ParamType = "integer"
+	ParamNumber  ParamType = "number"
+	ParamBoolean ParamType = "boolean"
+	// ParamDuration is a Go duration string ("30s") or a number of seconds
+	ParamDuration ParamType = "duration"
+	// ParamTemplate is a string rendered as a template (see ParseTemplate)
+	ParamTemplate ParamType = "template"
+	ParamArray    ParamType = "array"
+	ParamObject   ParamType = "object"
+	// ParamAny accepts any value; it is only meant for ports
+	ParamAny ParamType = "any"
+)
+
+// ParamSpec describes a parameter of a node type, i.e. a key of the
+// "config" object of its node definitions
+type ParamSpec struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Required    bool      `json:"required,omitempty"`
+	// Default is the value used when the parameter is not set, if any
+	Default interface{} `json:"default,omitempty"`
+	// Enum lists the accepted values, if restricted
+	Enum []string `json:"enum,omitempty"`
+	// Min and Max bound numeric parameters, when set
+	Min *float64 `json:"minimum,omitempty"`
+	Max *float64 `json:"maximum,omitempty"`
+}
+
+// Limit returns a pointer to a bound, for ParamSpec.Min and ParamSpec.Max
+func Limit(value float64) *float64 {
+	return &value
+}
+
+// PortSpec describes a key of the input a node consumes or of the output it
+// produces
+type PortSpec struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Description string    `json:"description,omitempty"`
+	// Optional inputs are used when present; optional outputs are only
+	// produced in some cases (e.g. dry runs), so they don't satisfy the
+	// inputs of other nodes
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Check validates the value of a parameter against its spec
+func (p ParamSpec) Check(value interface{}) error {
+	switch p.Type {
+	case ParamString, ParamTemplate:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string, got %s", p.Name, describeValue(value))
+		}
+		if p.Type == ParamTemplate {
+			if _, err := ParseTemplate(p.Name, str); err != nil {
+				return err
+			}
+		}
+		if len(p.Enum) > 0 && !contains(p.Enum, str) {
+			message := fmt.Sprintf("%s must be one of %s, got %q", p.Name, strings.Join(p.Enum, ", "), str)
+			if suggestion := Suggest(str, p.Enum); suggestion != "" {
+				message += fmt.Sprintf("; did you mean %q?", suggestion)
+			}
+			return fmt.Errorf("%s", message)
+		}
+	case ParamInteger, ParamNumber:
+		number, ok := numberValue(value)
+		if !ok || p.Type == ParamInteger && number != math.Trunc(number) {
+			article := "a"
+			if p.Type == ParamInteger {
+				article = "an"
+			}
+			return fmt.Errorf("%s must be %s %s, got %s", p.Name, article, p.Type, describeValue(value))
+		}
+		if p.Min != nil && number < *p.Min {
+			return fmt.Errorf("%s must be at least %g, got %g", p.Name, *p.Min, number)
+		}
+		if p.Max != nil && number > *p.Max {
+			return fmt.Errorf("%s must be at most %g, got %g", p.Name, *p.Max, number)
+		}
+	case ParamBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean, got %s", p.Name, describeValue(value))
+		}
+	case ParamDuration:
+		switch v := value.(type) {
+		case string:
+			if _, err := time.ParseDuration(v); err != nil {
+				return fmt.Errorf("%s must be a duration such as \"30s\", got %q", p.Name, v)
+			}
+		default:
+			if _, ok := numberValue(value); !ok {
+				return fmt.Errorf("%s must be a duration such as \"30s\", got %s", p.Name, describeValue(value))
+			}
+		}
+	case ParamArray:
+		switch value.(type) {
+		case []interface{}, []string:
+		default:
+			return fmt.Errorf("%s must be an array, got %s", p.Name, describeValue(value))
+		}
+	case ParamObject:
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s must be an object, got %s", p.Name, describeValue(value))
+		}
+	}
+
+	return nil
+}
+
+// CheckParameters validates the "config" object of a node definition
+// against the parameters of its type: unknown and missing parameters, types,
+// enums and ranges. It returns every problem found, and nothing when the
+// parameters of the type are not described.
+func (t NodeType) CheckParameters(params map[string]interface{}) []error {
+	if t.Parameters == nil {
+		return nil
+	}
+
+	specs := make(map[string]ParamSpec, len(t.Parameters))
+	names := make([]string, 0, len(t.Parameters))
+	for _, spec := range t.Parameters {
+		specs[spec.Name] = spec
+		names = append(names, spec.Name)
+	}
+
+	var problems []error
+	for _, spec := range t.Parameters {
+		if _, exists := params[spec.Name]; !exists && spec.Required {
+			problems = append(problems, fmt.Errorf("required parameter %s is missing", spec.Name))
+		}
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		spec, exists := specs[key]
+		if !exists {
+			problem := fmt.Sprintf("unknown parameter %q", key)
+			if suggestion := Suggest(key, names); suggestion != "" {
+				problem += fmt.Sprintf("; did you mean %q?", suggestion)
+			}
+			problems = append(problems, fmt.Errorf("%s", problem))
+			continue
+		}
+		if err := spec.Check(params[key]); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	return problems
+}
+
+// numberValue converts a JSON number, or a Go number set programmatically,
+// to a float64
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// describeValue formats a value of the wrong type for error messages
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case nil:
+		return "null"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Suggest returns the candidate closest to a misspelled name, or an empty
+// string when none is close enough
+func Suggest(name string, candidates []string) string {
+	best, bestDistance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		if distance := editDistance(strings.ToLower(name), strings.ToLower(candidate)); distance <= bestDistance && distance < len(candidate) {
+			if best == "" || distance < bestDistance {
+				best, bestDistance = candidate, distance
+			}
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
@@ -0,0 +1,113 @@
+package base
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory creates a node from its configuration
+type Factory func(config NodeConfig) (Node, error)
+
+// NodeType describes a node type that pipelines can use: how to create it,
+// and the metadata the builder, the schemas and the documentation are
+// generated from
+type NodeType struct {
+	// Type is the name used in the "type" field of node definitions
+	Type string `json:"type"`
+	// Category groups related node types (e.g. "ai", "publishers"); it is
+	// the package of nodes/ implementing them
+	Category string `json:"category,omitempty"`
+	// Description is a short human-readable summary of the node type
+	Description string `json:"description,omitempty"`
+	// CredentialService is the credentials section the node reads its
+	// credential from (e.g. "openai"), or empty if it needs no credentials
+	CredentialService string `json:"credential_service,omitempty"`
+	// CredentialServiceParam names the parameter selecting the credentials
+	// section instead, e.g. "provider"; CredentialService is used when the
+	// parameter isn't set
+	CredentialServiceParam string `json:"credential_service_param,omitempty"`
+	// DefaultCredential is used when a node definition has no "credentials"
+	// field; when empty the field is required
+	DefaultCredential string `json:"default_credential,omitempty"`
+	// CredentialRefs returns the other credentials the parameters of a node
+	// refer to, e.g. those of its fallbacks, which the builder looks up
+	// along with its own. Nil when nodes only use their own credential.
+	CredentialRefs func(parameters map[string]interface{}) []CredentialRef `json:"-"`
+	// InputPaths returns the dotted input paths the parameters of a node
+	// read, keyed by parameter, e.g. its history. The pipeline checks that
+	// the paths under NodesKey name an upstream node. Nil when the
+	// parameters read none.
+	InputPaths func(parameters map[string]interface{}) map[string]string `json:"-"`
+	// InputsFor returns the inputs a node reads with the given parameters,
+	// e.g. the keys its message template references. The data contract
+	// checks use them instead of Inputs. Nil when Inputs apply to every
+	// node of the type.
+	InputsFor func(parameters map[string]interface{}) []PortSpec `json:"-"`
+	// Parameters describes the keys of the node "config" object. Nil means
+	// they are not described and any key is accepted.
+	Parameters []ParamSpec `json:"parameters"`
+	// Inputs are the keys the node reads at the top level of its input.
+	// Required ones must be produced by one of its upstream nodes.
+	Inputs []PortSpec `json:"inputs"`
+	// Outputs are the keys the node produces. Nil means they are unknown,
+	// which disables the data contract checks of the nodes depending on it.
+	Outputs []PortSpec `json:"outputs"`
+	// Factory creates nodes of this type
+	Factory Factory `json:"-"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]NodeType)
+)
+
+// RegisterNodeType makes a node type available to pipelines. It is meant to
+// be called from the init function of the package implementing the node, and
+// panics if the type is invalid or already registered.
+func RegisterNodeType(nodeType NodeType) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if nodeType.Type == "" {
+		panic("nodes: RegisterNodeType with empty type")
+	}
+	if nodeType.Factory == nil {
+		panic(fmt.Sprintf("nodes: RegisterNodeType %s with nil factory", nodeType.Type))
+	}
+	if _, exists := registry[nodeType.Type]; exists {
+		panic(fmt.Sprintf("nodes: RegisterNodeType called twice for %s", nodeType.Type))
+	}
+
+	registry[nodeType.Type] = nodeType
+}
+
+// LookupNodeType returns the registered node type with the given name
+func LookupNodeType(name string) (NodeType, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	nodeType, exists := registry[name]
+	return nodeType, exists
+}
+
+// NodeTypes returns every registered node type, sorted by name
+func NodeTypes() []NodeType {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]NodeType, 0, len(registry))
+	for _, nodeType := range registry {
+		types = append(types, nodeType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Type < types[j].Type
+	})
+
+	return types
+}
+
+// NodeTypeOf returns the registered type of a node
+func NodeTypeOf(node Node) (NodeType, bool) {
+	return LookupNodeType(node.Config().Type)
+}
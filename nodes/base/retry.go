@@ -0,0 +1,17 @@
+package base
+
+// RetryPolicy configures how a failing node is retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int `json:"max_attempts"`
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff Duration `json:"initial_backoff,omitempty"`
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff Duration `json:"max_backoff,omitempty"`
+	// Multiplier grows the delay after every attempt
+	Multiplier float64 `json:"multiplier,omitempty"`
+	// Jitter randomizes each delay by up to this fraction (0 to 1)
+	Jitter float64 `json:"jitter,omitempty"`
+	// RetryOn lists the error classes that are retried, e.g. "rate_limit"
+	RetryOn []string `json:"retry_on,omitempty"`
+}
@@ -0,0 +1,198 @@
+package base
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Template is a parsed node template, such as a prompt or a message template.
+//
+// Templates use Go's text/template syntax and are rendered with the node
+// input, so upstream outputs are addressed by key:
+//
+//	{{ .generated_text }}
+//	{{ .nodes.text_generator.model_used | upper }}
+//	{{ now | date "02/01/2006" }}
+//
+// Referencing a key that is not present in the input is an error; use
+// {{ index . "topic" | default "success" }} for optional values.
+type Template struct {
+	name string
+	tmpl *template.Template
+}
+
+// ParseTemplate parses a template, reporting syntax errors with the template name
+func ParseTemplate(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(templateFuncs).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", name, err)
+	}
+
+	return &Template{name: name, tmpl: tmpl}, nil
+}
+
+// Render executes the template with the given data
+func (t *Template) Render(data map[string]interface{}) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", t.name, err)
+	}
+
+	return sb.String(), nil
+}
+
+// Fields returns the top-level input keys the template reads, such as
+// generated_text for {{ .generated_text | upper }}, in the order they appear.
+// Keys read inside a range or with block, where the dot is another value,
+// and keys read with index are not included.
+func (t *Template) Fields() []string {
+	var fields []string
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			fields = append(fields, key)
+		}
+	}
+
+	var walk func(node parse.Node, root bool)
+	walkPipe := func(pipe *parse.PipeNode, root bool) {
+		if pipe == nil {
+			return
+		}
+		for _, cmd := range pipe.Cmds {
+			for _, arg := range cmd.Args {
+				walk(arg, root)
+			}
+		}
+	}
+	walk = func(node parse.Node, root bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, root)
+			}
+		case *parse.ActionNode:
+			walkPipe(n.Pipe, root)
+		case *parse.PipeNode:
+			walkPipe(n, root)
+		case *parse.FieldNode:
+			if root {
+				add(n.Ident[0])
+			}
+		case *parse.ChainNode:
+			walk(n.Node, root)
+		case *parse.VariableNode:
+			// $ is always the input
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				add(n.Ident[1])
+			}
+		case *parse.IfNode:
+			walkPipe(n.Pipe, root)
+			walk(n.List, root)
+			walk(n.ElseList, root)
+		case *parse.RangeNode:
+			walkPipe(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		case *parse.WithNode:
+			walkPipe(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		}
+	}
+	if t.tmpl.Tree != nil {
+		walk(t.tmpl.Tree.Root, true)
+	}
+	return fields
+}
+
+// RenderTemplate parses and executes a template in a single step
+func RenderTemplate(name, text string, data map[string]interface{}) (string, error) {
+	tmpl, err := ParseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	return tmpl.Render(data)
+}
+
+// templateFuncs are the built-in functions available to every template
+var templateFuncs = template.FuncMap{
+	"now":      time.Now,
+	"date":     formatDate,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"trim":     strings.TrimSpace,
+	"truncate": truncate,
+	"join":     join,
+	"default":  defaultValue,
+}
+
+// formatDate formats a time.Time or an RFC 3339 string with a Go layout
+func formatDate(layout string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout), nil
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return "", fmt.Errorf("date: cannot parse %q as RFC 3339", v)
+		}
+		return t.Format(layout), nil
+	default:
+		return "", fmt.Errorf("date: unsupported value of type %T", value)
+	}
+}
+
+// truncate shortens a string to at most length characters, adding an ellipsis when cut
+func truncate(length int, s string) string {
+	runes := []rune(s)
+	if length < 0 || len(runes) <= length {
+		return s
+	}
+	if length <= 3 {
+		return string(runes[:length])
+	}
+	return string(runes[:length-3]) + "..."
+}
+
+// join concatenates the elements of a list with a separator
+func join(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a list, got %T", list)
+	}
+
+	parts := make([]string, v.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// defaultValue returns fallback when value is nil or empty
+func defaultValue(fallback, value interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if v.Len() == 0 {
+			return fallback
+		}
+	}
+	return value
+}
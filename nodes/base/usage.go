@@ -0,0 +1,57 @@
+package base
+
+import "encoding/json"
+
+// UsageKey is the output key under which nodes calling a paid API, such as
+// text_generator, report the tokens and the cost of the call
+const UsageKey = "usage"
+
+// Usage is the tokens and the cost of model calls
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	// Cost is in US dollars
+	Cost float64 `json:"cost"`
+	// Unpriced is set when a model is missing from the price table, making
+	// Cost a lower bound
+	Unpriced bool `json:"unpriced,omitempty"`
+
+	// Provider, Model and Credential identify a single call
+	Provider   string `json:"provider,omitempty"`
+	Model      string `json:"model,omitempty"`
+	Credential string `json:"credential,omitempty"`
+}
+
+// Add adds the tokens and the cost of other to u
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+	u.Unpriced = u.Unpriced || other.Unpriced
+}
+
+// Output returns the usage as an output value, the way it reads once a run
+// is saved and loaded again
+func (u Usage) Output() map[string]interface{} {
+	var output map[string]interface{}
+	data, _ := json.Marshal(u)
+	json.Unmarshal(data, &output)
+	return output
+}
+
+// UsageOf returns the usage a node reported in its output
+func UsageOf(output map[string]interface{}) (Usage, bool) {
+	var usage Usage
+	value, exists := output[UsageKey]
+	if !exists {
+		return usage, false
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil || json.Unmarshal(data, &usage) != nil {
+		return usage, false
+	}
+	return usage, true
+}
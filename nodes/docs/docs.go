@@ -0,0 +1,187 @@
+// Package docs generates the node reference of docs/NODES_DOCUMENTATION.md
+// from the metadata of the registered node types, so the documentation
+// doesn't drift from the code.
+package docs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"automation-chain/nodes/base"
+)
+
+// Markers delimiting the generated reference in a Markdown document
+const (
+	BeginMarker = "<!-- BEGIN GENERATED NODE REFERENCE: go run main.go nodes docs -update docs/NODES_DOCUMENTATION.md -->"
+	EndMarker   = "<!-- END GENERATED NODE REFERENCE -->"
+)
+
+// categories lists the known categories, in documentation order
+var categories = []struct {
+	name  string
+	title string
+}{
+	{"ai", "🤖 AI Nodes"},
+	{"publishers", "📤 Publisher Nodes"},
+	{"input", "📥 Input Nodes"},
+	{"media", "🎨 Media Nodes"},
+	{"utility", "🔧 Utility Nodes"},
+}
+
+// Reference returns the Markdown reference of the given node types, grouped
+// by category
+func Reference(types []base.NodeType) string {
+	grouped := make(map[string][]base.NodeType)
+	for _, nodeType := range types {
+		grouped[nodeType.Category] = append(grouped[nodeType.Category], nodeType)
+	}
+
+	var b strings.Builder
+	for _, category := range categories {
+		writeCategory(&b, category.title, grouped[category.name])
+		delete(grouped, category.name)
+	}
+	for _, nodeType := range types {
+		if others, exists := grouped[nodeType.Category]; exists {
+			writeCategory(&b, "Other Nodes", others)
+			delete(grouped, nodeType.Category)
+		}
+	}
+
+	return b.String()
+}
+
+// Update replaces the generated reference of a Markdown document
+func Update(document string, types []base.NodeType) (string, error) {
+	begin := strings.Index(document, BeginMarker)
+	end := strings.Index(document, EndMarker)
+	if begin < 0 || end < begin {
+		return "", fmt.Errorf("generated reference markers not found")
+	}
+
+	return document[:begin+len(BeginMarker)] + "\n\n" + Reference(types) + document[end:], nil
+}
+
+// UpdateFile replaces the generated reference of a Markdown file
+func UpdateFile(path string, types []base.NodeType) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	document, err := Update(string(data), types)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return os.WriteFile(path, []byte(document), 0o644)
+}
+
+func writeCategory(b *strings.Builder, title string, types []base.NodeType) {
+	if len(types) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "### %s\n\n", title)
+	for _, nodeType := range types {
+		writeNodeType(b, nodeType)
+	}
+}
+
+func writeNodeType(b *strings.Builder, nodeType base.NodeType) {
+	fmt.Fprintf(b, "#### `%s`\n\n", nodeType.Type)
+	if nodeType.Description != "" {
+		fmt.Fprintf(b, "%s.\n\n", nodeType.Description)
+	}
+	if nodeType.Category != "" {
+		fmt.Fprintf(b, "- **Package**: `nodes/%s`\n", nodeType.Category)
+	}
+	switch {
+	case nodeType.CredentialService == "":
+		b.WriteString("- **Credentials**: none\n")
+	case nodeType.DefaultCredential != "":
+		fmt.Fprintf(b, "- **Credentials**: `%s` (default: `%s`)\n", nodeType.CredentialService, nodeType.DefaultCredential)
+	default:
+		fmt.Fprintf(b, "- **Credentials**: `%s` (required)\n", nodeType.CredentialService)
+	}
+	if nodeType.CredentialServiceParam != "" {
+		fmt.Fprintf(b, "- **Credentials section**: selected by the `%s` parameter (default: `%s`)\n", nodeType.CredentialServiceParam, nodeType.CredentialService)
+	}
+
+	b.WriteString("\n**Parameters**\n\n")
+	switch {
+	case nodeType.Parameters == nil:
+		b.WriteString("Not described; any key is accepted.\n\n")
+	case len(nodeType.Parameters) == 0:
+		b.WriteString("None.\n\n")
+	default:
+		b.WriteString("| Parameter | Type | Required | Default | Description |\n")
+		b.WriteString("|-----------|------|----------|---------|-------------|\n")
+		for _, param := range nodeType.Parameters {
+			required := "No"
+			if param.Required {
+				required = "Yes"
+			}
+			fmt.Fprintf(b, "| `%s` | %s | %s | %s | %s |\n", param.Name, param.Type, required, defaultValue(param.Default), cell(paramDescription(param)))
+		}
+		b.WriteString("\n")
+	}
+
+	writePorts(b, "Inputs", nodeType.Inputs, "None required.")
+	writePorts(b, "Outputs", nodeType.Outputs, "Not declared.")
+}
+
+func writePorts(b *strings.Builder, title string, ports []base.PortSpec, none string) {
+	fmt.Fprintf(b, "**%s**\n\n", title)
+	if len(ports) == 0 {
+		fmt.Fprintf(b, "%s\n\n", none)
+		return
+	}
+
+	for _, port := range ports {
+		kind := string(port.Type)
+		if port.Optional {
+			kind += ", optional"
+		}
+		fmt.Fprintf(b, "- `%s` (%s): %s\n", port.Name, kind, port.Description)
+	}
+	b.WriteString("\n")
+}
+
+// paramDescription completes the description of a parameter with its
+// accepted values
+func paramDescription(param base.ParamSpec) string {
+	description := param.Description
+	if len(param.Enum) > 0 {
+		description += ". One of `" + strings.Join(param.Enum, "`, `") + "`"
+	}
+	switch {
+	case param.Min != nil && param.Max != nil:
+		description += fmt.Sprintf(". From %g to %g", *param.Min, *param.Max)
+	case param.Min != nil:
+		description += fmt.Sprintf(". At least %g", *param.Min)
+	case param.Max != nil:
+		description += fmt.Sprintf(". At most %g", *param.Max)
+	}
+	return description
+}
+
+// defaultValue formats the default of a parameter as JSON
+func defaultValue(value interface{}) string {
+	if value == nil {
+		return "-"
+	}
+	var data strings.Builder
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return "`" + cell(strings.TrimSuffix(data.String(), "\n")) + "`"
+}
+
+// cell escapes the pipes of a table cell
+func cell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
@@ -4,15 +4,51 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 
 	"automation-chain/nodes/base"
 	"automation-chain/services"
 )
 
+func init() {
+	base.RegisterNodeType(base.NodeType{
+		Type:              "telegram_publisher",
+		Category:          "publishers",
+		Description:       "Publishes messages to a Telegram channel",
+		CredentialService: "telegram",
+		Parameters:        base.ParamSpecsOf(telegramPublisherParams{}),
+		Inputs:            []base.PortSpec{generatedTextInput},
+		InputsFor:         messageInputs,
+		Outputs: []base.PortSpec{
+			{Name: "published", Type: base.ParamBoolean, Description: "Whether the message was sent; false in dry runs"},
+			{Name: "channel_id", Type: base.ParamString, Description: "Channel the message was sent to"},
+			{Name: "platform", Type: base.ParamString, Description: `Always "telegram"`},
+			{Name: "dry_run", Type: base.ParamBoolean, Optional: true, Description: "Set in dry runs, when the message was not sent"},
+			{Name: "message", Type: base.ParamString, Optional: true, Description: "Rendered message, in dry runs"},
+			{Name: "parse_mode", Type: base.ParamString, Optional: true, Description: "Parse mode the message would be sent with, in dry runs"},
+		},
+		Factory: func(config base.NodeConfig) (base.Node, error) {
+			return NewTelegramPublisherNode(config)
+		},
+	})
+}
+
+// generatedTextInput is the text the default message template publishes
+var generatedTextInput = base.PortSpec{Name: "generated_text", Type: base.ParamString, Description: "Text to publish, usually from a text_generator node; required when the message_template reads it"}
+
+// telegramPublisherParams are the parameters of telegram_publisher nodes.
+// The parse modes are the services.TelegramParseMode* constants.
+type telegramPublisherParams struct {
+	Message   *base.Template `param:"message_template" default:"💪 *Daily Motivation*\n\n{{ .generated_text }}\n\n✨ Have an amazing day!" desc:"Message sent to the channel, rendered with the node input"`
+	ParseMode string         `param:"parse_mode" enum:"Markdown,MarkdownV2,HTML" desc:"Formatting of the message; sent as plain text when not set"`
+}
+
 // TelegramPublisherNode publishes messages to a Telegram channel
 type TelegramPublisherNode struct {
-	telegram *services.TelegramService
-	config   base.NodeConfig
+	telegram  *services.TelegramService
+	config    base.NodeConfig
+	message   *base.Template
+	parseMode string
 }
 
 // NewTelegramPublisherNode creates a new Telegram publisher node
@@ -28,9 +64,16 @@ func NewTelegramPublisherNode(config base.NodeConfig) (*TelegramPublisherNode, e
 		}
 	}
 
+	var params telegramPublisherParams
+	if err := base.DecodeParams(config, &params); err != nil {
+		return nil, err
+	}
+
 	return &TelegramPublisherNode{
-		telegram: telegram,
-		config:   config,
+		telegram:  telegram,
+		config:    config,
+		message:   params.Message,
+		parseMode: params.ParseMode,
 	}, nil
 }
 
@@ -53,29 +96,45 @@ func (n *TelegramPublisherNode) Validate() error {
 	return nil
 }
 
-// Execute publishes the generated text to Telegram
+// Execute publishes the rendered message to Telegram. In a dry run the
+// message is rendered and validated but not sent.
 func (n *TelegramPublisherNode) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
 	log.Println("Publishing to Telegram...")
 
-	// Get the generated text from the previous node
-	generatedText, ok := input["generated_text"].(string)
-	if !ok {
-		return nil, fmt.Errorf("generated_text not found in input or not a string")
+	// Make sure the previous node produced the text to publish, when the
+	// message reads it
+	if slices.Contains(n.message.Fields(), generatedTextInput.Name) {
+		if _, ok := input[generatedTextInput.Name].(string); !ok {
+			return nil, fmt.Errorf("generated_text not found in input or not a string")
+		}
 	}
 
-	// Get message template from config or use default
-	messageTemplate := "💪 *Daily Motivation*\n\n%s\n\n✨ Have an amazing day!"
-	if val, exists := n.config.Parameters["message_template"]; exists {
-		if template, ok := val.(string); ok {
-			messageTemplate = template
-		}
+	// Render the message with the pipeline data
+	message, err := n.message.Render(input)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create the message with formatting
-	message := fmt.Sprintf(messageTemplate, generatedText)
+	// Check the message before sending it, so formatting mistakes are
+	// caught by dry runs too
+	if err := services.ValidateTelegramMessage(message, n.parseMode); err != nil {
+		return nil, err
+	}
+
+	if base.IsDryRun(ctx) {
+		log.Printf("Dry run: message not sent to channel: %s", n.telegram.GetChannelID())
+		return map[string]interface{}{
+			"published":  false,
+			"dry_run":    true,
+			"channel_id": n.telegram.GetChannelID(),
+			"platform":   "telegram",
+			"message":    message,
+			"parse_mode": n.parseMode,
+		}, nil
+	}
 
 	// Send message using Telegram service
-	if err := n.telegram.SendMessage(ctx, message); err != nil {
+	if err := n.telegram.SendMessage(ctx, message, n.parseMode); err != nil {
 		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
 
@@ -86,4 +145,28 @@ func (n *TelegramPublisherNode) Execute(ctx context.Context, input map[string]in
 		"channel_id": n.telegram.GetChannelID(),
 		"platform":   "telegram",
 	}, nil
-} 
\ No newline at end of file
+}
+
+// messageInputs returns the input keys the message template of a node reads,
+// so that a node handling errors with a template that only reads the error
+// doesn't require generated_text
+func messageInputs(parameters map[string]interface{}) []base.PortSpec {
+	var params telegramPublisherParams
+	if err := base.DecodeParams(base.NodeConfig{Parameters: parameters}, &params); err != nil {
+		// The builder reports the invalid parameters
+		return nil
+	}
+
+	var inputs []base.PortSpec
+	for _, key := range params.Message.Fields() {
+		switch key {
+		case base.NodesKey:
+			// Always part of the input
+		case generatedTextInput.Name:
+			inputs = append(inputs, generatedTextInput)
+		default:
+			inputs = append(inputs, base.PortSpec{Name: key, Description: "Read by the message_template"})
+		}
+	}
+	return inputs
+}
@@ -0,0 +1,455 @@
+package base
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"automation-chain/nodes/base"
+	"automation-chain/services"
+)
+
+// budgetAlertsFile is the file of the run store directory recording the
+// budget alerts already emitted, so each is emitted once per period
+const budgetAlertsFile = "budget_alerts.json"
+
+// SetBudgets sets the budgets of the model calls of the pipeline
+func (p *Pipeline) SetBudgets(budgets []base.Budget) {
+	p.budgets = budgets
+}
+
+// SetCredentialBudgets sets the budgets of the model calls made with a
+// credential, given as service/name. They count the calls of every
+// pipeline using the credential.
+func (p *Pipeline) SetCredentialBudgets(credential string, budgets []base.Budget) {
+	if p.credentialBudgets == nil {
+		p.credentialBudgets = make(map[string][]base.Budget)
+	}
+	p.credentialBudgets[credential] = budgets
+}
+
+// SetAlertCredential sets the telegram credential the alerts of the budgets
+// naming it are sent with
+func (p *Pipeline) SetAlertCredential(name string, values map[string]interface{}) {
+	if p.alertCredentials == nil {
+		p.alertCredentials = make(map[string]map[string]interface{})
+	}
+	p.alertCredentials[name] = values
+}
+
+// hasBudgets reports whether any budget applies to the runs of the pipeline
+func (p *Pipeline) hasBudgets() bool {
+	return len(p.budgets) > 0 || len(p.credentialBudgets) > 0
+}
+
+// budgetScope is what a list of budgets applies to
+type budgetScope struct {
+	// name describes the scope, e.g. "credential openai/premium"
+	name    string
+	budgets []base.Budget
+	matches func(record UsageRecord) bool
+}
+
+// credentialScope and pipelineScope name the budget scopes of a credential
+// and a pipeline
+func credentialScope(credential string) string { return "credential " + credential }
+func pipelineScope(pipeline string) string     { return "pipeline " + pipeline }
+
+// budgetGuard enforces the budgets of a run. It counts the usage recorded in
+// the ledger of the run store, and the pending usage of the runs sharing the
+// store: the usage of their calls, only recorded once a run ends, and the
+// reserves of the calls in flight.
+type budgetGuard struct {
+	pipeline *Pipeline
+	dryRun   bool
+	pending  *pendingUsage
+
+	mu sync.Mutex
+	// alerted holds the alerts emitted without a run store
+	alerted map[string]bool
+}
+
+// pendingUsage holds the usage of model calls the ledger doesn't have yet.
+// A run store shares it between the runs of a process, so concurrent runs
+// count each other's calls.
+type pendingUsage struct {
+	mu      sync.Mutex
+	next    int
+	records map[int]pendingRecord
+}
+
+// pendingRecord is the reserve of a call in flight, or the usage of a
+// settled call of a run still running
+type pendingRecord struct {
+	guard   *budgetGuard
+	settled bool
+	UsageRecord
+}
+
+// newBudgetGuard creates the budget guard of a run. Without a run store,
+// budgets only count the calls of the run.
+func (p *Pipeline) newBudgetGuard(dryRun bool) *budgetGuard {
+	pending := &pendingUsage{records: make(map[int]pendingRecord)}
+	if p.store != nil {
+		pending = &p.store.pending
+	} else {
+		log.Printf("Warning: pipeline %s has budgets but no run store; they only count the calls of each run", p.name)
+	}
+	return &budgetGuard{pipeline: p, dryRun: dryRun, pending: pending, alerted: make(map[string]bool)}
+}
+
+// scopes returns the budgets applying to a call with a credential
+func (g *budgetGuard) scopes(credential string) []budgetScope {
+	var scopes []budgetScope
+	if budgets := g.pipeline.credentialBudgets[credential]; len(budgets) > 0 {
+		scopes = append(scopes, budgetScope{
+			name:    credentialScope(credential),
+			budgets: budgets,
+			matches: func(record UsageRecord) bool { return record.Credential == credential },
+		})
+	}
+	if len(g.pipeline.budgets) > 0 {
+		scopes = append(scopes, budgetScope{
+			name:    pipelineScope(g.pipeline.name),
+			budgets: g.pipeline.budgets,
+			matches: func(record UsageRecord) bool { return record.Pipeline == g.pipeline.name },
+		})
+	}
+	return scopes
+}
+
+// budgetAlert is an alert due once a check is done
+type budgetAlert struct {
+	scope  budgetScope
+	budget base.Budget
+	used   float64
+}
+
+// Check checks a call against every budget applying to it, and holds its
+// reserve until it is settled. An exhausted budget with a fallback model
+// switches the call to it; without one, the call is refused.
+func (g *budgetGuard) Check(ctx context.Context, call base.BudgetCall) (base.BudgetDecision, error) {
+	decision := base.BudgetDecision{Model: call.Model}
+	scopes := g.scopes(call.Credential)
+	if len(scopes) == 0 {
+		return decision, nil
+	}
+
+	now := time.Now()
+	reserve := call.Reserve
+	var alerts []budgetAlert
+	err := func() error {
+		// The check and the reservation are atomic, so concurrent calls
+		// count each other's reserves
+		g.pending.mu.Lock()
+		defer g.pending.mu.Unlock()
+
+		for _, scope := range scopes {
+			for _, budget := range scope.budgets {
+				usage, err := g.periodUsage(scope, budget, now)
+				if err != nil {
+					return err
+				}
+
+				used := budget.Used(usage)
+				expected := used + budget.Used(reserve)
+				if expected > budget.Limit() {
+					if budget.FallbackModel == "" {
+						return &base.BudgetError{Scope: scope.name, Budget: budget, Used: used}
+					}
+					if decision.Model != budget.FallbackModel {
+						log.Printf("Budget %s of %s exhausted, falling back from %s to %s", budget, scope.name, decision.Model, budget.FallbackModel)
+						decision.Model = budget.FallbackModel
+						reserve = reserveOf(reserve, decision.Model)
+					}
+					continue
+				}
+				if budget.WarnAt > 0 && expected >= budget.WarnAt*budget.Limit() {
+					alerts = append(alerts, budgetAlert{scope: scope, budget: budget, used: used})
+				}
+			}
+		}
+
+		g.pending.next++
+		decision.Reservation = g.pending.next
+		g.pending.records[decision.Reservation] = pendingRecord{guard: g, UsageRecord: UsageRecord{
+			Time:     now,
+			Pipeline: g.pipeline.name,
+			Usage: base.Usage{
+				TotalTokens: reserve.TotalTokens,
+				Cost:        reserve.Cost,
+				Credential:  call.Credential,
+			},
+		}}
+		return nil
+	}()
+	if err != nil {
+		return decision, err
+	}
+
+	for _, alert := range alerts {
+		g.alert(ctx, alert.scope, alert.budget, alert.used, now)
+	}
+	return decision, nil
+}
+
+// reserveOf prices the reserve of a call for the model it falls back to. The
+// reserved tokens are completion tokens, e.g. the max_tokens of the call.
+func reserveOf(reserve base.Usage, model string) base.Usage {
+	reserve.Cost, _ = services.Prices().Cost(model, services.Usage{CompletionTokens: reserve.TotalTokens})
+	return reserve
+}
+
+// periodUsage returns what a budget scope used in the period of a budget
+// holding now: the usage of the ledger, the usage of the calls of the runs
+// still running and the reserves of the calls in flight. It is called with
+// g.pending.mu held.
+func (g *budgetGuard) periodUsage(scope budgetScope, budget base.Budget, now time.Time) (base.Usage, error) {
+	var usage base.Usage
+	if g.pipeline.store != nil {
+		var err error
+		// Without the ledger the budgets can't be enforced, so the call is refused
+		if usage, err = g.pipeline.store.periodUsage(scope.name, budget.Period, budget.Start(now)); err != nil {
+			return usage, fmt.Errorf("failed to check budgets: %w", err)
+		}
+	}
+
+	since := budget.Start(now)
+	for _, record := range g.pending.records {
+		if record.settled && record.Time.Before(since) {
+			continue
+		}
+		if scope.matches(record.UsageRecord) {
+			usage.Add(record.Usage)
+		}
+	}
+	return usage, nil
+}
+
+// Spend settles a call, counting what it used instead of its reserve until
+// the run records its usage
+func (g *budgetGuard) Spend(decision base.BudgetDecision, usage base.Usage) {
+	// No budget applies to calls without a reservation
+	if decision.Reservation == 0 {
+		return
+	}
+	g.pending.mu.Lock()
+	defer g.pending.mu.Unlock()
+	g.pending.records[decision.Reservation] = pendingRecord{guard: g, settled: true, UsageRecord: UsageRecord{
+		Time:     time.Now(),
+		Pipeline: g.pipeline.name,
+		Usage:    usage,
+	}}
+}
+
+// Release settles a call that failed, releasing its reserve
+func (g *budgetGuard) Release(decision base.BudgetDecision) {
+	g.pending.mu.Lock()
+	defer g.pending.mu.Unlock()
+	delete(g.pending.records, decision.Reservation)
+}
+
+// close drops the usage of the calls of the run, once the ledger has it
+func (g *budgetGuard) close() {
+	g.pending.mu.Lock()
+	defer g.pending.mu.Unlock()
+	for reservation, record := range g.pending.records {
+		if record.guard == g {
+			delete(g.pending.records, reservation)
+		}
+	}
+}
+
+// alert emits the alert of a budget past its warning threshold, once per
+// period: it is logged, and sent with the budget's alert credential if set
+func (g *budgetGuard) alert(ctx context.Context, scope budgetScope, budget base.Budget, used float64, now time.Time) {
+	key := fmt.Sprintf("%s %s %s", scope.name, budget, budget.Start(now).Format("2006-01-02"))
+	if !g.firstAlert(key) {
+		return
+	}
+
+	message := fmt.Sprintf("⚠️ Budget alert: %s used %.0f%% of its budget %s (%s)",
+		scope.name, 100*used/budget.Limit(), budget, formatBudgetUsed(budget, used))
+	log.Print(message)
+	if budget.Alert == "" {
+		return
+	}
+	if g.dryRun {
+		log.Printf("Dry run: budget alert not sent with %s", budget.Alert)
+		return
+	}
+
+	telegram := services.NewTelegram()
+	err := telegram.LoadConfig(g.pipeline.alertCredentials[budget.Alert])
+	if err == nil {
+		err = telegram.SendMessage(ctx, message, "")
+	}
+	if err != nil {
+		log.Printf("Warning: failed to send budget alert with %s: %v", budget.Alert, err)
+	}
+}
+
+// firstAlert reports whether the alert with key wasn't emitted yet, and
+// marks it emitted
+func (g *budgetGuard) firstAlert(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.pipeline.store == nil {
+		if g.alerted[key] {
+			return false
+		}
+		g.alerted[key] = true
+		return true
+	}
+
+	first, err := g.pipeline.store.markAlert(key)
+	if err != nil {
+		log.Printf("Warning: failed to record budget alert: %v", err)
+	}
+	return first
+}
+
+// formatBudgetUsed formats what a period used, in the unit of its budget
+func formatBudgetUsed(budget base.Budget, used float64) string {
+	if budget.Tokens > 0 {
+		return fmt.Sprintf("%.0f tokens", used)
+	}
+	return fmt.Sprintf("$%.2f", used)
+}
+
+// alertsMu serializes the updates of the budget alerts file by the runs of
+// a process
+var alertsMu sync.Mutex
+
+// markAlert records that an alert was emitted, reporting false when it
+// already was
+func (s *RunStore) markAlert(key string) (bool, error) {
+	alertsMu.Lock()
+	defer alertsMu.Unlock()
+
+	path := filepath.Join(s.dir, budgetAlertsFile)
+	alerts := make(map[string]time.Time)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return true, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &alerts); err != nil {
+			return true, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	if _, exists := alerts[key]; exists {
+		return false, nil
+	}
+
+	alerts[key] = time.Now()
+	if data, err = json.MarshalIndent(alerts, "", "  "); err != nil {
+		return true, err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return true, err
+	}
+	return true, os.WriteFile(path, data, 0o600)
+}
+
+// ledgerTotals caches the usage of the ledger per budget scope and period.
+// The ledger is only appended to, so it is read from where the last read
+// stopped.
+type ledgerTotals struct {
+	mu     sync.Mutex
+	offset int64
+	totals map[periodKey]base.Usage
+}
+
+// periodKey identifies the usage of a budget scope over a period
+type periodKey struct {
+	scope  string
+	period string
+	start  int64
+}
+
+// periodUsage returns what the ledger recorded for a budget scope in the
+// period starting at start
+func (s *RunStore) periodUsage(scope, period string, start time.Time) (base.Usage, error) {
+	s.ledger.mu.Lock()
+	defer s.ledger.mu.Unlock()
+
+	if err := s.readLedger(); err != nil {
+		return base.Usage{}, err
+	}
+	return s.ledger.totals[periodKey{scope: scope, period: period, start: start.Unix()}], nil
+}
+
+// readLedger adds the records appended to the ledger since the last read to
+// the totals. It is called with s.ledger.mu held.
+func (s *RunStore) readLedger() error {
+	file, err := os.Open(s.ledgerPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	// A ledger shorter than what was read was replaced, and is read again
+	if s.ledger.totals == nil || info.Size() < s.ledger.offset {
+		s.ledger.offset = 0
+		s.ledger.totals = make(map[periodKey]base.Usage)
+	}
+	if info.Size() == s.ledger.offset {
+		return nil
+	}
+
+	if _, err := file.Seek(s.ledger.offset, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	// A record being appended is read once complete
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+
+	for len(data) > 0 {
+		line, rest, _ := bytes.Cut(data, []byte("\n"))
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record UsageRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				return fmt.Errorf("%s: invalid usage record at offset %d: %w", s.ledgerPath(), s.ledger.offset, err)
+			}
+			s.ledger.add(record)
+		}
+		s.ledger.offset += int64(len(line) + 1)
+		data = rest
+	}
+	return nil
+}
+
+// add adds a record to the totals of its scopes and periods
+func (l *ledgerTotals) add(record UsageRecord) {
+	scopes := []string{pipelineScope(record.Pipeline)}
+	if record.Credential != "" {
+		scopes = append(scopes, credentialScope(record.Credential))
+	}
+	for _, scope := range scopes {
+		for _, period := range []string{base.BudgetPerDay, base.BudgetPerMonth} {
+			key := periodKey{scope: scope, period: period, start: base.Budget{Period: period}.Start(record.Time).Unix()}
+			usage := l.totals[key]
+			usage.Add(record.Usage)
+			l.totals[key] = usage
+		}
+	}
+}
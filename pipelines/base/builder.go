@@ -1,28 +1,31 @@
 package base
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"sort"
+	"strings"
+	"time"
 
-	"automation-chain/nodes/ai"
+	"automation-chain/config"
+	// Register the built-in node types
+	_ "automation-chain/nodes/all"
 	"automation-chain/nodes/base"
-	"automation-chain/nodes/publishers"
 )
 
 // PipelineBuilder builds pipelines from configuration
 type PipelineBuilder struct {
-	credentials map[string]interface{}
+	credentials *config.CredentialsManager
 }
 
-// NewPipelineBuilder creates a new pipeline builder
-func NewPipelineBuilder() *PipelineBuilder {
-	// Load credentials from config/credentials.json
-	credentials, err := loadCredentials("config/credentials.json")
-	if err != nil {
-		log.Printf("Warning: Failed to load credentials: %v", err)
-		credentials = make(map[string]interface{})
+// NewPipelineBuilder creates a new pipeline builder that looks up the
+// credentials of the nodes in the given manager. A nil manager has no
+// credentials.
+func NewPipelineBuilder(credentials *config.CredentialsManager) *PipelineBuilder {
+	if credentials == nil {
+		credentials = config.NewCredentialsManager()
 	}
 
 	return &PipelineBuilder{
@@ -30,94 +33,253 @@ func NewPipelineBuilder() *PipelineBuilder {
 	}
 }
 
-// BuildPipeline builds a pipeline from node definitions
+// BuildPipeline builds a pipeline from node definitions. Every node is
+// created and validated, and the pipeline is checked as a whole (dependencies,
+// options, credentials and the inputs each node requires) before anything
+// runs; the returned *ValidationError lists every problem found.
 func (b *PipelineBuilder) BuildPipeline(name string, nodeDefs []base.NodeDefinition) (*Pipeline, error) {
 	log.Printf("Building pipeline: %s", name)
 
 	pipeline := NewPipeline(name)
 
+	// Pipelines that don't declare any dependency keep running their
+	// nodes one after another, in the order they are defined. Error
+	// handlers only run when a failure is routed to them, so they are
+	// left out of the chain.
+	sequential := true
+	handlers := make(map[string]bool)
 	for _, nodeDef := range nodeDefs {
-		node, err := b.createNode(nodeDef)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create node %s: %w", nodeDef.Name, err)
+		if len(nodeDef.DependsOn) > 0 {
+			sequential = false
+		}
+		if nodeDef.OnError == base.OnErrorRoute && nodeDef.ErrorHandler != "" {
+			handlers[nodeDef.ErrorHandler] = true
 		}
+	}
 
-		pipeline.AddNode(node)
+	var problems []error
+	previous := ""
+	for _, nodeDef := range nodeDefs {
+		node, errs := b.createNode(nodeDef)
+		if len(errs) == 0 {
+			if err := node.Validate(); err != nil {
+				errs = []error{err}
+			}
+		}
+		if len(errs) == 0 {
+			errs = b.addCredentialBudgets(pipeline, node.Config())
+		}
+		if len(errs) > 0 {
+			for _, err := range errs {
+				problems = append(problems, fmt.Errorf("node %s: %w", nodeDef.ID, err))
+			}
+			// Keep a placeholder so the rest of the pipeline is still checked
+			node = &invalidNode{config: base.NodeConfig{ID: nodeDef.ID, Type: nodeDef.Type, Name: nodeDef.Name}}
+		}
+
+		dependsOn := nodeDef.DependsOn
+		if sequential && !handlers[nodeDef.ID] {
+			if previous != "" {
+				dependsOn = []string{previous}
+			}
+			previous = nodeDef.ID
+		}
+
+		pipeline.AddNodeWithOptions(node, NodeOptions{
+			DependsOn:    dependsOn,
+			Retry:        nodeDef.Retry,
+			Timeout:      time.Duration(nodeDef.Timeout),
+			OnError:      nodeDef.OnError,
+			ErrorHandler: nodeDef.ErrorHandler,
+		})
+	}
+
+	problems = append(problems, pipeline.problems()...)
+	if err := newValidationError(name, problems); err != nil {
+		return nil, err
 	}
 
 	log.Printf("Pipeline %s built successfully with %d nodes", name, pipeline.GetNodeCount())
 	return pipeline, nil
 }
 
-// createNode creates a node from node definition
-func (b *PipelineBuilder) createNode(nodeDef base.NodeDefinition) (base.Node, error) {
-	// Create node config
-	nodeConfig := base.NodeConfig{
-		ID:         nodeDef.ID,
-		Type:       nodeDef.Type,
-		Name:       nodeDef.Name,
-		Parameters: nodeDef.Config,
+// BuildPipelineFromConfig builds a pipeline from its configuration, applying
+// the pipeline-level settings
+func (b *PipelineBuilder) BuildPipelineFromConfig(config *PipelineConfig) (*Pipeline, error) {
+	pipeline, err := b.BuildPipeline(config.Name, config.Nodes)
+	if err != nil {
+		return nil, err
 	}
 
-	switch nodeDef.Type {
-	case "text_generator":
-		// Get OpenAI credential from node definition
-		openaiCredential := nodeDef.Credentials
-		if openaiCredential == "" {
-			openaiCredential = "default" // fallback to default
+	timeout := time.Duration(config.Timeout)
+	if timeout == 0 {
+		timeout = DefaultPipelineTimeout
+	}
+	pipeline.SetTimeout(timeout)
+	pipeline.source = config.Path
+	pipeline.SetDryRun(config.DryRun)
+
+	var problems []error
+	for i, budget := range config.Budgets {
+		if err := budget.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("budgets[%d]: %w", i, err))
 		}
+	}
+	problems = append(problems, b.addAlertCredentials(pipeline, config.Budgets)...)
+	if err := newValidationError(config.Name, problems); err != nil {
+		return nil, err
+	}
+	pipeline.SetBudgets(config.Budgets)
 
-		// Get OpenAI config from credentials
-		if openaiServices, exists := b.credentials["openai"]; exists {
-			if openaiMap, ok := openaiServices.(map[string]interface{}); ok {
-				if openaiConfig, exists := openaiMap[openaiCredential]; exists {
-					if configMap, ok := openaiConfig.(map[string]interface{}); ok {
-						// Add OpenAI config to node parameters
-						nodeConfig.Parameters["openai"] = configMap
-					}
-				}
-			}
+	if err := pipeline.Validate(); err != nil {
+		return nil, err
+	}
+
+	return pipeline, nil
+}
+
+// addCredentialBudgets sets the budgets of the credentials of a node, read
+// from their budgets field
+func (b *PipelineBuilder) addCredentialBudgets(pipeline *Pipeline, nodeConfig base.NodeConfig) []error {
+	credentials := make(map[string]map[string]interface{}, len(nodeConfig.Credentials)+1)
+	if service, _, found := strings.Cut(nodeConfig.Credential, "/"); found {
+		credentials[nodeConfig.Credential], _ = nodeConfig.Parameters[service].(map[string]interface{})
+	}
+	for key, values := range nodeConfig.Credentials {
+		credentials[key] = values
+	}
+
+	keys := make([]string, 0, len(credentials))
+	for key := range credentials {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []error
+	for _, key := range keys {
+		values := credentials[key]
+		budgets, err := base.DecodeBudgets(values["budgets"])
+		if err != nil {
+			problems = append(problems, fmt.Errorf("credential %s: %w", key, err))
+			continue
+		}
+		if len(budgets) == 0 {
+			continue
 		}
 
-		return ai.NewTextGeneratorNode(nodeConfig)
+		pipeline.SetCredentialBudgets(key, budgets)
+		problems = append(problems, b.addAlertCredentials(pipeline, budgets)...)
+	}
+	return problems
+}
 
-	case "telegram_publisher":
-		// Get Telegram credential from node definition
-		telegramCredential := nodeDef.Credentials
-		if telegramCredential == "" {
-			return nil, fmt.Errorf("telegram_publisher node requires 'credentials' field")
+// addAlertCredentials looks up the telegram credentials budget alerts are
+// sent with
+func (b *PipelineBuilder) addAlertCredentials(pipeline *Pipeline, budgets []base.Budget) []error {
+	var problems []error
+	for _, budget := range budgets {
+		if budget.Alert == "" {
+			continue
 		}
+		values, err := b.credentials.Credential("telegram", budget.Alert)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("budget alert: %w", err))
+			continue
+		}
+		pipeline.SetAlertCredential(budget.Alert, values)
+	}
+	return problems
+}
+
+// createNode creates a node from node definition using the node type
+// registry. The parameters and the credential are checked first, returning
+// every problem found; the node is only created when there are none.
+func (b *PipelineBuilder) createNode(nodeDef base.NodeDefinition) (base.Node, []error) {
+	nodeType, exists := base.LookupNodeType(nodeDef.Type)
+	if !exists {
+		return nil, []error{fmt.Errorf("unknown node type: %s", nodeDef.Type)}
+	}
 
-		// Get Telegram config from credentials
-		if telegramServices, exists := b.credentials["telegram"]; exists {
-			if telegramMap, ok := telegramServices.(map[string]interface{}); ok {
-				if telegramConfig, exists := telegramMap[telegramCredential]; exists {
-					if configMap, ok := telegramConfig.(map[string]interface{}); ok {
-						// Add Telegram config to node parameters
-						nodeConfig.Parameters["telegram"] = configMap
-					}
-				}
+	problems := nodeType.CheckParameters(nodeDef.Config)
+
+	// Create node config, copying the parameters so the definition is left untouched
+	nodeConfig := base.NodeConfig{
+		ID:         nodeDef.ID,
+		Type:       nodeDef.Type,
+		Name:       nodeDef.Name,
+		Parameters: make(map[string]interface{}, len(nodeDef.Config)+1),
+	}
+	for key, value := range nodeDef.Config {
+		nodeConfig.Parameters[key] = value
+	}
+
+	if service := credentialService(nodeType, nodeDef.Config); service != "" {
+		credential := nodeDef.Credentials
+		if credential == "" {
+			credential = nodeType.DefaultCredential
+		}
+
+		// Add the service config from credentials to node parameters
+		if credential == "" {
+			problems = append(problems, fmt.Errorf("%s node requires 'credentials' field", nodeDef.Type))
+		} else if configMap, err := b.credentials.Credential(service, credential); err != nil {
+			problems = append(problems, err)
+		} else {
+			nodeConfig.Parameters[service] = configMap
+			nodeConfig.Credential = service + "/" + credential
+		}
+	}
+	if nodeType.CredentialRefs != nil {
+		for _, ref := range nodeType.CredentialRefs(nodeDef.Config) {
+			configMap, err := b.credentials.Credential(ref.Service, ref.Name)
+			if err != nil {
+				problems = append(problems, err)
+				continue
+			}
+			if nodeConfig.Credentials == nil {
+				nodeConfig.Credentials = make(map[string]map[string]interface{})
 			}
+			nodeConfig.Credentials[ref.String()] = configMap
 		}
+	}
 
-		return publishers.NewTelegramPublisherNode(nodeConfig)
+	if len(problems) > 0 {
+		return nil, problems
+	}
 
-	default:
-		return nil, fmt.Errorf("unknown node type: %s", nodeDef.Type)
+	node, err := nodeType.Factory(nodeConfig)
+	var paramsErr *base.ParamsError
+	switch {
+	case errors.As(err, &paramsErr):
+		// The problems are reported with the node ID by BuildPipeline
+		return nil, paramsErr.Problems
+	case err != nil:
+		return nil, []error{err}
 	}
+	return node, nil
 }
 
-// loadCredentials loads credentials from JSON file
-func loadCredentials(filePath string) (map[string]interface{}, error) {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
+// credentialService returns the credentials section of a node: the one its
+// parameters select, if its type allows it, or the one of its type
+func credentialService(nodeType base.NodeType, parameters map[string]interface{}) string {
+	if param := nodeType.CredentialServiceParam; param != "" {
+		if service, ok := parameters[param].(string); ok && service != "" {
+			return service
+		}
 	}
+	return nodeType.CredentialService
+}
 
-	var credentials map[string]interface{}
-	if err := json.Unmarshal(data, &credentials); err != nil {
-		return nil, err
-	}
+// invalidNode stands in for a node that could not be created, so the
+// pipeline can still be validated as a whole. It is never executed.
+type invalidNode struct {
+	config base.NodeConfig
+}
+
+func (n *invalidNode) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("node %s is invalid", n.config.ID)
+}
 
-	return credentials, nil
-} 
\ No newline at end of file
+func (n *invalidNode) Name() string            { return n.config.Name }
+func (n *invalidNode) Config() base.NodeConfig { return n.config }
+func (n *invalidNode) Validate() error         { return fmt.Errorf("node %s is invalid", n.config.ID) }
@@ -0,0 +1,71 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"automation-chain/nodes/base"
+)
+
+// DefaultPipelinesDir is where pipeline configuration files live
+const DefaultPipelinesDir = "config/pipelines"
+
+// PipelineConfig represents the configuration of a pipeline
+type PipelineConfig struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Schedule    string                `json:"schedule"`
+	Timezone    string                `json:"timezone,omitempty"`
+	Timeout     base.Duration         `json:"timeout,omitempty"`
+	DryRun      bool                  `json:"dry_run,omitempty"`
+	Budgets     []base.Budget         `json:"budgets,omitempty"`
+	Nodes       []base.NodeDefinition `json:"nodes"`
+
+	// Path is the file the configuration was loaded from
+	Path string `json:"-"`
+}
+
+// PipelineConfigPath returns the path of the configuration file of a pipeline
+func PipelineConfigPath(name string) string {
+	return filepath.Join(DefaultPipelinesDir, name+".json")
+}
+
+// LoadPipelineConfig loads pipeline configuration from JSON file
+func LoadPipelineConfig(filePath string) (*PipelineConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config PipelineConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+	config.Path = filePath
+
+	return &config, nil
+}
+
+// LoadPipelineConfigs loads every pipeline configuration file in a directory,
+// sorted by file name
+func LoadPipelineConfigs(dir string) ([]*PipelineConfig, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	configs := make([]*PipelineConfig, 0, len(files))
+	for _, file := range files {
+		config, err := LoadPipelineConfig(file)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
@@ -0,0 +1,241 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"automation-chain/config"
+	"automation-chain/nodes/base"
+	"automation-chain/services"
+)
+
+// completion is the outcome of a node run
+type completion struct {
+	id       string
+	output   map[string]interface{}
+	attempts int
+	err      error
+	// handling is the ID of the failed node when the run was an error handler
+	handling string
+}
+
+// execution holds the state of a single pipeline run
+type execution struct {
+	pipeline *Pipeline
+	graph    *graph
+	result   *Result
+	nodes    map[string]base.Node
+
+	ctx         context.Context
+	remaining   map[string]int
+	completions chan completion
+	running     int
+	started     int
+	err         error
+
+	// checkpoint is called after every node completes, when set
+	checkpoint func()
+}
+
+// newExecution prepares a run of a validated pipeline
+func newExecution(p *Pipeline, g *graph, result *Result) *execution {
+	e := &execution{
+		pipeline:    p,
+		graph:       g,
+		result:      result,
+		nodes:       make(map[string]base.Node, len(p.nodes)),
+		remaining:   make(map[string]int, len(g.order)),
+		completions: make(chan completion),
+	}
+
+	for _, node := range p.nodes {
+		e.nodes[node.Config().ID] = node
+	}
+	for _, id := range g.order {
+		e.remaining[id] = len(g.dependencies[id])
+	}
+
+	return e
+}
+
+// run executes the nodes until all of them have completed or the run fails
+func (e *execution) run(ctx context.Context) error {
+	runCtx := ctx
+	if e.pipeline.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		runCtx, cancelTimeout = context.WithTimeout(ctx, e.pipeline.timeout)
+		defer cancelTimeout()
+	}
+
+	var cancel context.CancelFunc
+	e.ctx, cancel = context.WithCancel(runCtx)
+	defer cancel()
+
+	// Nodes reused from a resumed run have already completed
+	for _, id := range e.graph.order {
+		if e.reused(id) {
+			for _, dependent := range e.graph.dependents[id] {
+				e.remaining[dependent]--
+			}
+		}
+	}
+	for _, id := range e.graph.order {
+		if e.remaining[id] == 0 && !e.reused(id) {
+			e.start(id)
+		}
+	}
+
+	for e.running > 0 {
+		c := <-e.completions
+		e.running--
+
+		if e.complete(c) && e.err == nil {
+			// The failure isn't handled: stop the run
+			e.err = c.err
+			cancel()
+		}
+		if e.checkpoint != nil {
+			e.checkpoint()
+		}
+	}
+
+	// Nodes that never started were skipped
+	for _, node := range e.pipeline.nodes {
+		id := node.Config().ID
+		if _, exists := e.result.Nodes[id]; !exists {
+			e.result.Nodes[id] = &NodeResult{Status: NodeSkipped}
+		}
+	}
+
+	if e.err != nil && e.pipeline.timeout > 0 && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		e.err = &TimeoutError{
+			Scope:   TimeoutScopePipeline,
+			Name:    e.pipeline.name,
+			Timeout: e.pipeline.timeout,
+			Err:     e.err,
+		}
+	}
+
+	return e.err
+}
+
+// reused reports whether the output of a node was taken from a resumed run
+func (e *execution) reused(id string) bool {
+	nodeResult, exists := e.result.Nodes[id]
+	return exists && nodeResult.Reused
+}
+
+// start runs a node of the graph in its own goroutine
+func (e *execution) start(id string) {
+	input := e.pipeline.inputFor(e.graph, id, e.result.Outputs)
+	e.launch(id, input, "")
+}
+
+// startErrorHandler runs the error handler a failed node was routed to
+func (e *execution) startErrorHandler(handlerID, failedID string, failure completion) {
+	input := e.pipeline.inputFor(e.graph, failedID, e.result.Outputs)
+	// Handlers usually send the message somewhere, so it mustn't hold secrets
+	input[base.ErrorKey] = map[string]interface{}{
+		"node_id":   failedID,
+		"node_name": e.nodes[failedID].Name(),
+		"message":   config.Redact(failure.err.Error()),
+		"class":     string(services.ClassifyError(failure.err)),
+		"attempts":  failure.attempts,
+	}
+
+	log.Printf("Routing failure of node %s to error handler %s", failedID, handlerID)
+	e.launch(handlerID, input, failedID)
+}
+
+// launch executes a node with the given input
+func (e *execution) launch(id string, input map[string]interface{}, handling string) {
+	node := e.nodes[id]
+	options := e.pipeline.options[id]
+
+	e.running++
+	e.started++
+	log.Printf("Executing node %d/%d: %s", e.started, len(e.pipeline.nodes), node.Name())
+
+	go func() {
+		output, attempts, err := runNode(e.ctx, node, input, options)
+		e.completions <- completion{id: id, output: output, attempts: attempts, err: err, handling: handling}
+	}()
+}
+
+// complete records the outcome of a node and starts the nodes it unblocks.
+// It reports whether the node failed in a way that must stop the run.
+func (e *execution) complete(c completion) bool {
+	node := e.nodes[c.id]
+	options := e.pipeline.options[c.id]
+
+	if c.err != nil {
+		log.Printf("Error in node %s: %v", node.Name(), c.err)
+		e.result.Nodes[c.id] = &NodeResult{
+			Status:     NodeFailed,
+			Error:      config.Redact(c.err.Error()),
+			ErrorClass: string(services.ClassifyError(c.err)),
+			Attempts:   c.attempts,
+		}
+
+		// Failures caused by the run being stopped are not handled
+		if e.err != nil || c.handling != "" {
+			return true
+		}
+
+		switch options.OnError {
+		case base.OnErrorContinue:
+			log.Printf("Continuing after failure of node %s; its dependents will be skipped", node.Name())
+			return false
+		case base.OnErrorRoute:
+			e.result.Nodes[c.id].HandledBy = options.ErrorHandler
+			e.startErrorHandler(options.ErrorHandler, c.id, c)
+			return false
+		default:
+			return true
+		}
+	}
+
+	log.Printf("Node %s completed successfully", node.Name())
+	if c.output == nil {
+		c.output = make(map[string]interface{})
+	}
+	e.result.Outputs[c.id] = c.output
+	e.result.Nodes[c.id] = &NodeResult{Status: NodeSucceeded, Attempts: c.attempts}
+
+	if e.err != nil {
+		return false
+	}
+	for _, dependent := range e.graph.dependents[c.id] {
+		e.remaining[dependent]--
+		if e.remaining[dependent] == 0 {
+			e.start(dependent)
+		}
+	}
+
+	return false
+}
+
+// runNode validates and executes a single node, retrying it according to
+// its policy. It returns the number of attempts made.
+func runNode(ctx context.Context, node base.Node, input map[string]interface{}, options NodeOptions) (map[string]interface{}, int, error) {
+	if err := node.Validate(); err != nil {
+		return nil, 0, fmt.Errorf("node %s validation failed: %w", node.Name(), err)
+	}
+
+	var output map[string]interface{}
+	attempts, err := newRetrier(options.Retry).run(ctx, node.Name(), func() error {
+		var err error
+		output, err = executeNode(ctx, node, input, options.Timeout)
+		return err
+	})
+	if err != nil {
+		if attempts > 1 {
+			return nil, attempts, fmt.Errorf("node %s failed after %d attempts: %w", node.Name(), attempts, err)
+		}
+		return nil, attempts, fmt.Errorf("node %s failed: %w", node.Name(), err)
+	}
+
+	return output, attempts, nil
+}
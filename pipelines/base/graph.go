@@ -0,0 +1,115 @@
+package base
+
+import (
+	"fmt"
+	"strings"
+)
+
+// graph is the dependency graph of a pipeline, keyed by node ID
+type graph struct {
+	order        []string
+	dependencies map[string][]string
+	dependents   map[string][]string
+}
+
+// buildGraph checks the node dependencies and returns them in topological order
+func buildGraph(ids []string, dependencies map[string][]string) (*graph, error) {
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if id == "" {
+			return nil, fmt.Errorf("node without id")
+		}
+		if known[id] {
+			return nil, fmt.Errorf("duplicate node id: %s", id)
+		}
+		known[id] = true
+	}
+
+	dependents := make(map[string][]string)
+	for _, id := range ids {
+		for _, dep := range dependencies[id] {
+			if dep == id {
+				return nil, fmt.Errorf("node %s depends on itself", id)
+			}
+			if !known[dep] {
+				return nil, fmt.Errorf("node %s depends on unknown node %s", id, dep)
+			}
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+
+	// Depth-first search keeping the declaration order stable
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(ids))
+	order := make([]string, 0, len(ids))
+	var path []string
+
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == id {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), id)
+			return fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[id] = visiting
+		path = append(path, id)
+		for _, dep := range dependencies[id] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+		order = append(order, id)
+		return nil
+	}
+
+	for _, id := range ids {
+		if err := visit(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return &graph{
+		order:        order,
+		dependencies: dependencies,
+		dependents:   dependents,
+	}, nil
+}
+
+// ancestors returns every node the given node transitively depends on, in topological order
+func (g *graph) ancestors(id string) []string {
+	seen := make(map[string]bool)
+	var collect func(string)
+	collect = func(current string) {
+		for _, dep := range g.dependencies[current] {
+			if !seen[dep] {
+				seen[dep] = true
+				collect(dep)
+			}
+		}
+	}
+	collect(id)
+
+	result := make([]string, 0, len(seen))
+	for _, candidate := range g.order {
+		if seen[candidate] {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
@@ -4,61 +4,401 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"automation-chain/nodes/base"
 )
 
-// Pipeline orchestrates the execution of nodes
+// Pipeline orchestrates the execution of nodes as a directed acyclic graph
 type Pipeline struct {
-	name  string
-	nodes []base.Node
+	name    string
+	nodes   []base.Node
+	options map[string]NodeOptions
+	timeout time.Duration
+	// store checkpoints the runs of the pipeline when set
+	store *RunStore
+	// source is the configuration file the pipeline was built from
+	source string
+	// dryRun makes every run of the pipeline a dry run
+	dryRun bool
+	// budgets limit the model calls of the pipeline, and credentialBudgets
+	// those of each credential (service/name)
+	budgets           []base.Budget
+	credentialBudgets map[string][]base.Budget
+	// alertCredentials are the telegram credentials budget alerts are sent with
+	alertCredentials map[string]map[string]interface{}
+}
+
+// NodeOptions configures how the pipeline runs a node
+type NodeOptions struct {
+	// DependsOn lists the IDs of the nodes that must complete before this one runs
+	DependsOn []string
+	// Retry is the retry policy of the node; nil means a single attempt
+	Retry *base.RetryPolicy
+	// Timeout limits each attempt of the node; zero means no limit
+	Timeout time.Duration
+	// OnError is what happens when the node fails (base.OnErrorFail by default)
+	OnError string
+	// ErrorHandler is the node failures are routed to with base.OnErrorRoute
+	ErrorHandler string
 }
 
 // NewPipeline creates a new pipeline instance
 func NewPipeline(name string) *Pipeline {
 	return &Pipeline{
-		name:  name,
-		nodes: make([]base.Node, 0),
+		name:    name,
+		nodes:   make([]base.Node, 0),
+		options: make(map[string]NodeOptions),
 	}
 }
 
-// AddNode adds a node to the pipeline
-func (p *Pipeline) AddNode(node base.Node) {
+// AddNode adds a node to the pipeline. dependsOn lists the IDs of the nodes
+// that must complete before this one runs; a node without dependencies starts
+// as soon as the pipeline does.
+func (p *Pipeline) AddNode(node base.Node, dependsOn ...string) {
+	p.AddNodeWithOptions(node, NodeOptions{DependsOn: dependsOn})
+}
+
+// AddNodeWithOptions adds a node to the pipeline with its execution options
+func (p *Pipeline) AddNodeWithOptions(node base.Node, options NodeOptions) {
+	options.DependsOn = append([]string{}, options.DependsOn...)
 	p.nodes = append(p.nodes, node)
+	p.options[node.Config().ID] = options
 	log.Printf("Added node: %s to pipeline: %s", node.Name(), p.name)
 }
 
-// Execute runs all nodes in the pipeline sequentially
-func (p *Pipeline) Execute(ctx context.Context) error {
-	log.Printf("Starting pipeline execution: %s", p.name)
-	
-	input := make(map[string]interface{})
-	
+// SetTimeout limits the duration of the whole run; zero means no limit
+func (p *Pipeline) SetTimeout(timeout time.Duration) {
+	p.timeout = timeout
+}
+
+// SetRunStore makes the pipeline checkpoint the state of its runs to store
+func (p *Pipeline) SetRunStore(store *RunStore) {
+	p.store = store
+}
+
+// SetDryRun makes every run of the pipeline a dry run, where nodes with side
+// effects only report what they would have done. A run is also dry when its
+// context was marked with base.WithDryRun.
+func (p *Pipeline) SetDryRun(dryRun bool) {
+	p.dryRun = dryRun
+}
+
+// Validate checks that the node dependencies form a directed acyclic graph,
+// that the node options are valid and that every node receives the inputs it
+// requires. It returns a *ValidationError listing every problem found.
+func (p *Pipeline) Validate() error {
+	return newValidationError(p.name, p.problems())
+}
+
+// problems returns every problem of the pipeline definition
+func (p *Pipeline) problems() []error {
+	var problems []error
+	if p.timeout < 0 {
+		problems = append(problems, fmt.Errorf("timeout must not be negative"))
+	}
+
+	handlers := p.errorHandlers()
+	ids := make(map[string]bool, len(p.nodes))
+	for _, node := range p.nodes {
+		ids[node.Config().ID] = true
+	}
+
+	// routed is the node whose failures each error handler handles
+	routed := make(map[string]string)
+	for _, node := range p.nodes {
+		id := node.Config().ID
+		options := p.options[id]
+
+		if err := validateRetryPolicy(options.Retry); err != nil {
+			problems = append(problems, fmt.Errorf("node %s: %w", id, err))
+		}
+		if options.Timeout < 0 {
+			problems = append(problems, fmt.Errorf("node %s: timeout must not be negative", id))
+		}
+
+		switch options.OnError {
+		case "", base.OnErrorFail, base.OnErrorContinue:
+			if options.ErrorHandler != "" {
+				problems = append(problems, fmt.Errorf("node %s: error_handler requires on_error %q", id, base.OnErrorRoute))
+			}
+		case base.OnErrorRoute:
+			switch {
+			case options.ErrorHandler == "":
+				problems = append(problems, fmt.Errorf("node %s: on_error %q requires an error_handler", id, base.OnErrorRoute))
+			case options.ErrorHandler == id:
+				problems = append(problems, fmt.Errorf("node %s: cannot be its own error handler", id))
+			case !ids[options.ErrorHandler]:
+				problems = append(problems, fmt.Errorf("node %s: unknown error handler %s", id, options.ErrorHandler))
+			case routed[options.ErrorHandler] != "":
+				// A handler is a single node: two failures handled at once
+				// would run it concurrently and record a single outcome
+				problems = append(problems, fmt.Errorf("node %s: error handler %s already handles the errors of %s", id, options.ErrorHandler, routed[options.ErrorHandler]))
+			default:
+				routed[options.ErrorHandler] = id
+			}
+		default:
+			problems = append(problems, fmt.Errorf("node %s: invalid on_error %q (expected fail, continue or route)", id, options.OnError))
+		}
+
+		if handlers[id] {
+			if len(options.DependsOn) > 0 {
+				problems = append(problems, fmt.Errorf("error handler %s cannot declare dependencies", id))
+			}
+			if options.OnError == base.OnErrorRoute {
+				problems = append(problems, fmt.Errorf("error handler %s cannot route its own errors", id))
+			}
+		}
+		for _, dep := range options.DependsOn {
+			if handlers[dep] {
+				problems = append(problems, fmt.Errorf("node %s cannot depend on error handler %s", id, dep))
+			}
+		}
+	}
+
+	if _, err := p.graph(); err != nil {
+		problems = append(problems, err)
+	}
+
+	problems = append(problems, p.contractProblems()...)
+	return append(problems, p.inputPathProblems()...)
+}
+
+// errorHandlers returns the IDs of the nodes used as error handlers. They
+// only run when a failure is routed to them.
+func (p *Pipeline) errorHandlers() map[string]bool {
+	handlers := make(map[string]bool)
+	for _, options := range p.options {
+		if options.OnError == base.OnErrorRoute && options.ErrorHandler != "" {
+			handlers[options.ErrorHandler] = true
+		}
+	}
+	return handlers
+}
+
+// graph builds the dependency graph of the pipeline nodes, leaving out error handlers
+func (p *Pipeline) graph() (*graph, error) {
+	handlers := p.errorHandlers()
+
+	ids := make([]string, 0, len(p.nodes))
+	dependencies := make(map[string][]string, len(p.nodes))
+	for _, node := range p.nodes {
+		id := node.Config().ID
+		if handlers[id] {
+			continue
+		}
+		ids = append(ids, id)
+		dependencies[id] = p.options[id].DependsOn
+	}
+	return buildGraph(ids, dependencies)
+}
+
+// Execute runs the pipeline nodes. Each node starts once all of its
+// dependencies have succeeded, so independent branches run concurrently.
+// A failing node fails the run and cancels the nodes still running, unless
+// its on_error mode continues or routes the failure to an error handler; the
+// nodes depending on it are then skipped. The returned result holds the
+// status of every node and the output of those that completed, even when
+// the run fails. With a run store, the state of the run is saved after
+// every node.
+func (p *Pipeline) Execute(ctx context.Context) (*Result, error) {
+	return p.execute(ctx, nil)
+}
+
+// Resume executes again the nodes of a previous run that did not succeed,
+// reusing the stored outputs of the nodes that did. The run is updated in
+// place.
+func (p *Pipeline) Resume(ctx context.Context, run *Run) (*Result, error) {
+	if run.Pipeline != p.name {
+		return nil, fmt.Errorf("run %s belongs to pipeline %s, not %s", run.ID, run.Pipeline, p.name)
+	}
+	if !run.Resumable() {
+		return nil, fmt.Errorf("run %s already completed successfully", run.ID)
+	}
+	return p.execute(ctx, run)
+}
+
+// execute runs the pipeline, resuming previous when it isn't nil
+func (p *Pipeline) execute(ctx context.Context, previous *Run) (*Result, error) {
+	dryRun := p.dryRun || base.IsDryRun(ctx)
+	if dryRun {
+		ctx = base.WithDryRun(ctx)
+		log.Printf("Dry run of pipeline %s: nodes with side effects only report what they would do", p.name)
+	}
+
+	var guard *budgetGuard
+	if p.hasBudgets() {
+		guard = p.newBudgetGuard(dryRun)
+		ctx = base.WithBudgetGuard(ctx, guard)
+	}
+
+	if previous != nil {
+		log.Printf("Resuming run %s of pipeline: %s", previous.ID, p.name)
+	} else {
+		log.Printf("Starting pipeline execution: %s", p.name)
+	}
+
+	ids := make([]string, len(p.nodes))
 	for i, node := range p.nodes {
-		log.Printf("Executing node %d/%d: %s", i+1, len(p.nodes), node.Name())
-		
-		// Validate node before execution
-		if err := node.Validate(); err != nil {
-			log.Printf("Node validation failed: %s - %v", node.Name(), err)
-			return fmt.Errorf("node %s validation failed: %w", node.Name(), err)
+		ids[i] = node.Config().ID
+	}
+	result := newResult(p.name, ids)
+	result.DryRun = dryRun
+
+	if err := p.Validate(); err != nil {
+		return result, err
+	}
+	g, _ := p.graph()
+
+	if previous != nil {
+		p.reuseOutputs(g, previous, result)
+	}
+
+	// Dry runs send nothing, so there is nothing worth resuming
+	e := newExecution(p, g, result)
+	var run *Run
+	if !dryRun {
+		run = p.startRun(previous, result)
+	}
+	if run != nil {
+		e.checkpoint = func() { p.saveRun(run) }
+	}
+
+	err := e.run(ctx)
+	if run != nil {
+		p.finishRun(run, err)
+	}
+	p.recordUsage(result)
+	if guard != nil {
+		guard.close()
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if failed := result.Failed(); len(failed) > 0 {
+		log.Printf("Pipeline %s execution completed with failed nodes: %v", p.name, failed)
+	} else {
+		log.Printf("Pipeline %s execution completed successfully!", p.name)
+	}
+	return result, nil
+}
+
+// reuseOutputs copies to result the outputs of the nodes that succeeded in a
+// previous run. Error handlers always run again when a failure is routed to them.
+func (p *Pipeline) reuseOutputs(g *graph, previous *Run, result *Result) {
+	for _, id := range g.order {
+		nodeResult, exists := previous.Nodes[id]
+		if !exists || nodeResult.Status != NodeSucceeded {
+			continue
+		}
+
+		output, exists := previous.Outputs[id]
+		if !exists {
+			continue
+		}
+
+		result.Outputs[id] = output
+		result.Nodes[id] = &NodeResult{Status: NodeSucceeded, Attempts: nodeResult.Attempts, Reused: true}
+		log.Printf("Reusing the output of node %s from run %s", id, previous.ID)
+	}
+}
+
+// startRun creates the record of a run, or reopens the resumed one, and
+// saves it. It returns nil when the pipeline has no run store.
+func (p *Pipeline) startRun(previous *Run, result *Result) *Run {
+	if p.store == nil {
+		return nil
+	}
+
+	run := previous
+	if run == nil {
+		run = &Run{
+			ID:        newRunID(),
+			Pipeline:  p.name,
+			Config:    p.source,
+			StartedAt: time.Now(),
 		}
-		
-		output, err := node.Execute(ctx, input)
-		if err != nil {
-			log.Printf("Error in node %s: %v", node.Name(), err)
-			return fmt.Errorf("node %s failed: %w", node.Name(), err)
+	} else {
+		run.Resumes++
+		run.Error = ""
+		run.FinishedAt = nil
+	}
+	run.Status = RunRunning
+	run.Nodes = result.Nodes
+	run.Outputs = result.Outputs
+	result.RunID = run.ID
+
+	p.saveRun(run)
+	return run
+}
+
+// finishRun records the outcome of a run
+func (p *Pipeline) finishRun(run *Run, err error) {
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = RunSucceeded
+	if usage := run.usage(); usage.TotalTokens > 0 || usage.Cost > 0 {
+		run.Usage = &usage
+	}
+	if err != nil {
+		run.Status = RunFailed
+		run.Error = err.Error()
+	}
+
+	p.saveRun(run)
+	if run.Resumable() {
+		log.Printf("Run %s saved; resume it with: resume %s", run.ID, run.ID)
+	}
+}
+
+// recordUsage logs the tokens and the cost of a run and appends them to the
+// usage ledger of the run store
+func (p *Pipeline) recordUsage(result *Result) {
+	records := result.usageRecords(time.Now())
+	if len(records) == 0 {
+		return
+	}
+
+	total := result.TotalUsage()
+	log.Printf("Pipeline %s used %d tokens ($%.6f)", p.name, total.TotalTokens, total.Cost)
+	if p.store == nil {
+		return
+	}
+	if err := p.store.RecordUsage(records); err != nil {
+		log.Printf("Warning: failed to record the usage of pipeline %s: %v", p.name, err)
+	}
+}
+
+// saveRun checkpoints a run. A failure to save doesn't stop the run, it only
+// makes it impossible to resume.
+func (p *Pipeline) saveRun(run *Run) {
+	if err := p.store.Save(run); err != nil {
+		log.Printf("Warning: failed to checkpoint run %s: %v", run.ID, err)
+	}
+}
+
+// inputFor builds the input of a node. The outputs of every upstream node
+// are merged at the top level, in execution order, and those of its direct
+// dependencies last so they take precedence, giving a "previous node" view.
+// They are also available by node ID under NodesKey.
+func (p *Pipeline) inputFor(g *graph, id string, outputs map[string]map[string]interface{}) map[string]interface{} {
+	input := make(map[string]interface{})
+	upstream := make(map[string]interface{})
+	for _, ancestor := range g.ancestors(id) {
+		for key, value := range outputs[ancestor] {
+			input[key] = value
 		}
-		
-		// Merge output with input for next node
-		for key, value := range output {
+		upstream[ancestor] = outputs[ancestor]
+	}
+	for _, dep := range g.dependencies[id] {
+		for key, value := range outputs[dep] {
 			input[key] = value
 		}
-		
-		log.Printf("Node %s completed successfully", node.Name())
 	}
-	
-	log.Printf("Pipeline %s execution completed successfully!", p.name)
-	return nil
+	input[base.NodesKey] = upstream
+
+	return input
 }
 
 // GetNodeCount returns the number of nodes in the pipeline
@@ -69,4 +409,4 @@ func (p *Pipeline) GetNodeCount() int {
 // GetName returns the pipeline name
 func (p *Pipeline) GetName() string {
 	return p.name
-} 
\ No newline at end of file
+}
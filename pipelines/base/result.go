@@ -0,0 +1,81 @@
+package base
+
+// NodeStatus is the final state of a node in a pipeline run
+type NodeStatus string
+
+const (
+	NodeSucceeded NodeStatus = "succeeded"
+	NodeFailed    NodeStatus = "failed"
+	NodeSkipped   NodeStatus = "skipped"
+)
+
+// NodeResult describes how a node ended in a pipeline run
+type NodeResult struct {
+	Status     NodeStatus `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	ErrorClass string     `json:"error_class,omitempty"`
+	Attempts   int        `json:"attempts,omitempty"`
+	// HandledBy is the error handler node the failure was routed to
+	HandledBy string `json:"handled_by,omitempty"`
+	// Reused is set when the output comes from the run being resumed
+	Reused bool `json:"reused,omitempty"`
+}
+
+// Result holds what every node of a pipeline run produced
+type Result struct {
+	Pipeline string                            `json:"pipeline"`
+	RunID    string                            `json:"run_id,omitempty"`
+	DryRun   bool                              `json:"dry_run,omitempty"`
+	Outputs  map[string]map[string]interface{} `json:"outputs"`
+	Nodes    map[string]*NodeResult            `json:"nodes"`
+
+	// order is the order in which nodes were added to the pipeline
+	order []string
+}
+
+// newResult creates an empty result for the given pipeline
+func newResult(pipeline string, order []string) *Result {
+	return &Result{
+		Pipeline: pipeline,
+		Outputs:  make(map[string]map[string]interface{}),
+		Nodes:    make(map[string]*NodeResult),
+		order:    order,
+	}
+}
+
+// Output returns the value a node produced under the given key
+func (r *Result) Output(nodeID, key string) (interface{}, bool) {
+	output, exists := r.Outputs[nodeID]
+	if !exists {
+		return nil, false
+	}
+
+	value, exists := output[key]
+	return value, exists
+}
+
+// Succeeded returns the IDs of the nodes that completed successfully
+func (r *Result) Succeeded() []string {
+	return r.withStatus(NodeSucceeded)
+}
+
+// Failed returns the IDs of the nodes that failed
+func (r *Result) Failed() []string {
+	return r.withStatus(NodeFailed)
+}
+
+// Skipped returns the IDs of the nodes that never ran
+func (r *Result) Skipped() []string {
+	return r.withStatus(NodeSkipped)
+}
+
+// withStatus returns the IDs of the nodes with the given status, in pipeline order
+func (r *Result) withStatus(status NodeStatus) []string {
+	ids := make([]string, 0)
+	for _, id := range r.order {
+		if nodeResult, exists := r.Nodes[id]; exists && nodeResult.Status == status {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
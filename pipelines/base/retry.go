@@ -0,0 +1,156 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"automation-chain/nodes/base"
+	"automation-chain/services"
+)
+
+// Retry defaults, used for the fields a retry policy leaves unset
+const (
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 30 * time.Second
+	defaultMultiplier     = 2.0
+)
+
+// defaultRetryOn are the error classes retried when a policy doesn't list any.
+// Authentication and invalid request errors are never retried by default,
+// since another attempt would fail the same way.
+var defaultRetryOn = []services.ErrorClass{
+	services.ErrorClassRateLimit,
+	services.ErrorClassNetwork,
+	services.ErrorClassServer,
+	services.ErrorClassTimeout,
+}
+
+// validateRetryPolicy checks the values of a retry policy
+func validateRetryPolicy(policy *base.RetryPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.MaxAttempts < 1 {
+		return fmt.Errorf("retry max_attempts must be at least 1, got %d", policy.MaxAttempts)
+	}
+	if policy.InitialBackoff < 0 || policy.MaxBackoff < 0 {
+		return fmt.Errorf("retry backoff must not be negative")
+	}
+	if policy.MaxBackoff > 0 && policy.InitialBackoff > policy.MaxBackoff {
+		return fmt.Errorf("retry initial_backoff (%s) is greater than max_backoff (%s)", policy.InitialBackoff, policy.MaxBackoff)
+	}
+	if policy.Multiplier != 0 && policy.Multiplier < 1 {
+		return fmt.Errorf("retry multiplier must be at least 1, got %g", policy.Multiplier)
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %g", policy.Jitter)
+	}
+	for _, class := range policy.RetryOn {
+		if !services.IsErrorClass(class) {
+			return fmt.Errorf("unknown error class in retry_on: %s", class)
+		}
+	}
+
+	return nil
+}
+
+// retrier applies a retry policy, with defaults filled in
+type retrier struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	jitter         float64
+	retryOn        map[services.ErrorClass]bool
+}
+
+// newRetrier creates a retrier for a policy. A nil policy makes a single attempt.
+func newRetrier(policy *base.RetryPolicy) *retrier {
+	r := &retrier{
+		maxAttempts:    1,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+		multiplier:     defaultMultiplier,
+		retryOn:        make(map[services.ErrorClass]bool),
+	}
+	if policy == nil {
+		return r
+	}
+
+	r.maxAttempts = policy.MaxAttempts
+	if policy.InitialBackoff > 0 {
+		r.initialBackoff = time.Duration(policy.InitialBackoff)
+	}
+	if policy.MaxBackoff > 0 {
+		r.maxBackoff = time.Duration(policy.MaxBackoff)
+	}
+	if policy.Multiplier > 0 {
+		r.multiplier = policy.Multiplier
+	}
+	r.jitter = policy.Jitter
+
+	if len(policy.RetryOn) == 0 {
+		for _, class := range defaultRetryOn {
+			r.retryOn[class] = true
+		}
+	}
+	for _, class := range policy.RetryOn {
+		r.retryOn[services.ErrorClass(class)] = true
+	}
+
+	return r
+}
+
+// shouldRetry reports whether an error is worth another attempt
+func (r *retrier) shouldRetry(err error) bool {
+	return r.retryOn[services.ClassifyError(err)]
+}
+
+// backoff returns the delay before the given retry (1 for the first retry)
+func (r *retrier) backoff(retry int, err error) time.Duration {
+	delay := float64(r.initialBackoff) * math.Pow(r.multiplier, float64(retry-1))
+	if delay > float64(r.maxBackoff) {
+		delay = float64(r.maxBackoff)
+	}
+	if r.jitter > 0 {
+		delay += delay * r.jitter * (2*rand.Float64() - 1)
+	}
+
+	// Never retry earlier than the service asked for
+	if retryAfter := services.RetryAfter(err); float64(retryAfter) > delay {
+		delay = float64(retryAfter)
+	}
+
+	return time.Duration(delay)
+}
+
+// run calls fn until it succeeds, fails with an error that isn't retryable,
+// runs out of attempts or ctx is done. It returns the number of attempts made.
+func (r *retrier) run(ctx context.Context, name string, fn func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt >= r.maxAttempts || !r.shouldRetry(err) || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		delay := r.backoff(attempt, err)
+		log.Printf("Node %s failed with a %s error (attempt %d/%d), retrying in %s: %v",
+			name, services.ClassifyError(err), attempt, r.maxAttempts, delay.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package base
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"automation-chain/config"
+	"automation-chain/nodes/base"
+)
+
+// DefaultRunsDir is where the state of pipeline runs is stored
+const DefaultRunsDir = "runs"
+
+// RunStatus is the state of a pipeline run
+type RunStatus string
+
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+)
+
+// Run is the persisted state of a pipeline run. It is checkpointed after
+// every node, so a failed or interrupted run can be resumed without
+// re-executing the nodes that already succeeded.
+type Run struct {
+	ID       string `json:"id"`
+	Pipeline string `json:"pipeline"`
+	// Config is the configuration file the pipeline was built from
+	Config     string                            `json:"config,omitempty"`
+	Status     RunStatus                         `json:"status"`
+	Error      string                            `json:"error,omitempty"`
+	StartedAt  time.Time                         `json:"started_at"`
+	FinishedAt *time.Time                        `json:"finished_at,omitempty"`
+	Resumes    int                               `json:"resumes,omitempty"`
+	Nodes      map[string]*NodeResult            `json:"nodes"`
+	Outputs    map[string]map[string]interface{} `json:"outputs"`
+	// Usage is the tokens and the cost of the nodes that reported them
+	Usage *base.Usage `json:"usage,omitempty"`
+}
+
+// Resumable reports whether the run has nodes left to execute
+func (r *Run) Resumable() bool {
+	if r.Status != RunSucceeded {
+		return true
+	}
+	for _, node := range r.Nodes {
+		if node.Status != NodeSucceeded {
+			return true
+		}
+	}
+	return false
+}
+
+// RunStore persists runs as JSON files in a directory, one file per run
+type RunStore struct {
+	dir string
+	// ledger caches the usage ledger for the budgets
+	ledger ledgerTotals
+	// pending holds the usage of the runs using the store that the ledger
+	// doesn't have yet
+	pending pendingUsage
+}
+
+// NewRunStore creates a run store writing to dir
+func NewRunStore(dir string) *RunStore {
+	return &RunStore{dir: dir, pending: pendingUsage{records: make(map[int]pendingRecord)}}
+}
+
+// runIDPattern matches the run IDs generated by newRunID
+var runIDPattern = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}-[0-9a-f]{6}$`)
+
+// newRunID returns a unique, time-ordered run ID
+func newRunID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// path returns the file of a run
+func (s *RunStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Save writes the state of a run, replacing the previous checkpoint. The
+// secrets of the loaded credentials are masked in the stored run.
+func (s *RunStore) Save(run *Run) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create runs directory: %w", err)
+	}
+
+	// Errors and outputs may echo credentials, e.g. in a request URL. They
+	// are masked before encoding, since JSON escapes some characters secrets
+	// may hold.
+	stored, err := run.redacted()
+	if err != nil {
+		return fmt.Errorf("failed to encode run %s: %w", run.ID, err)
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run %s: %w", run.ID, err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated run
+	tmp := s.path(run.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save run %s: %w", run.ID, err)
+	}
+	if err := os.Rename(tmp, s.path(run.ID)); err != nil {
+		return fmt.Errorf("failed to save run %s: %w", run.ID, err)
+	}
+
+	return nil
+}
+
+// redacted returns a copy of the run whose errors and outputs have the
+// secrets of the loaded credentials masked
+func (r *Run) redacted() (*Run, error) {
+	stored := *r
+	stored.Error = config.Redact(r.Error)
+
+	stored.Nodes = make(map[string]*NodeResult, len(r.Nodes))
+	for id, node := range r.Nodes {
+		masked := *node
+		masked.Error = config.Redact(node.Error)
+		stored.Nodes[id] = &masked
+	}
+
+	stored.Outputs = make(map[string]map[string]interface{}, len(r.Outputs))
+	for id, output := range r.Outputs {
+		masked := make(map[string]interface{}, len(output))
+		for key, value := range output {
+			var err error
+			if masked[key], err = redactValue(value); err != nil {
+				return nil, fmt.Errorf("output %s of node %s: %w", key, id, err)
+			}
+		}
+		stored.Outputs[id] = masked
+	}
+	return &stored, nil
+}
+
+// redactValue masks the secrets in the strings of an output value. Values
+// of other types are converted to their JSON form first, which is what the
+// run is stored and loaded as anyway.
+func redactValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, int, int64, float64, json.Number:
+		return v, nil
+	case string:
+		return config.Redact(v), nil
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if masked[i], err = redactValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return masked, nil
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var err error
+			if masked[key], err = redactValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return masked, nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var generic interface{}
+		if err := decoder.Decode(&generic); err != nil {
+			return nil, err
+		}
+		return redactValue(generic)
+	}
+}
+
+// Load reads a run by ID
+func (s *RunStore) Load(id string) (*Run, error) {
+	if !runIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid run ID: %s", id)
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("run %s not found in %s", id, s.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse run %s: %w", id, err)
+	}
+
+	return &run, nil
+}
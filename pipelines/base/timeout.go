@@ -0,0 +1,89 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"automation-chain/nodes/base"
+)
+
+// DefaultPipelineTimeout is used for pipelines that don't configure a timeout
+const DefaultPipelineTimeout = 30 * time.Second
+
+// Timeout scopes
+const (
+	TimeoutScopePipeline = "pipeline"
+	TimeoutScopeNode     = "node"
+)
+
+// TimeoutError reports that a pipeline or a node exceeded its timeout.
+// It matches context.DeadlineExceeded with errors.Is, so it is classified as
+// a timeout by services.ClassifyError.
+type TimeoutError struct {
+	Scope   string
+	Name    string
+	Timeout time.Duration
+	Err     error
+}
+
+// Error describes which pipeline or node timed out
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("%s %s timed out after %s", e.Scope, e.Name, e.Timeout)
+	if e.Err != nil && !errors.Is(e.Err, context.DeadlineExceeded) {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the error reported when the deadline expired, if any
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes TimeoutError match context.DeadlineExceeded
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// executeNode runs a single attempt of a node, enforcing its timeout. The
+// attempt is abandoned as soon as its context is done, even if the node
+// ignores the context.
+func executeNode(ctx context.Context, node base.Node, input map[string]interface{}, timeout time.Duration) (map[string]interface{}, error) {
+	attemptCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		output map[string]interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		output, err := node.Execute(attemptCtx, input)
+		done <- outcome{output: output, err: err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-done:
+	case <-attemptCtx.Done():
+		result.err = attemptCtx.Err()
+	}
+
+	// Only report a node timeout when the node's own deadline expired, not the pipeline's
+	if result.err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return nil, &TimeoutError{
+			Scope:   TimeoutScopeNode,
+			Name:    node.Config().ID,
+			Timeout: timeout,
+			Err:     result.err,
+		}
+	}
+
+	return result.output, result.err
+}
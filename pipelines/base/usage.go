@@ -0,0 +1,264 @@
+package base
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"automation-chain/nodes/base"
+)
+
+// UsageLedgerFile is the file of the run store directory where the usage of
+// every node that reported one is appended
+const UsageLedgerFile = "usage.jsonl"
+
+// UsageRecord is an entry of the usage ledger: the tokens and the cost of a
+// node in a run
+type UsageRecord struct {
+	Time     time.Time `json:"time"`
+	Pipeline string    `json:"pipeline"`
+	RunID    string    `json:"run_id,omitempty"`
+	Node     string    `json:"node"`
+	// Channels are the channels the run published to, sharing the cost
+	Channels []string `json:"channels,omitempty"`
+	DryRun   bool     `json:"dry_run,omitempty"`
+	base.Usage
+}
+
+// Usage returns the usage every node reported, keyed by node ID
+func (r *Result) Usage() map[string]base.Usage {
+	usage := make(map[string]base.Usage)
+	for id, output := range r.Outputs {
+		if nodeUsage, exists := base.UsageOf(output); exists {
+			usage[id] = nodeUsage
+		}
+	}
+	return usage
+}
+
+// TotalUsage returns the tokens and the cost of the whole run
+func (r *Result) TotalUsage() base.Usage {
+	var total base.Usage
+	for _, usage := range r.Usage() {
+		total.Add(usage)
+	}
+	return total
+}
+
+// usage returns the tokens and the cost of the nodes of a run, including
+// those reused when it was resumed
+func (r *Run) usage() base.Usage {
+	var total base.Usage
+	for _, output := range r.Outputs {
+		if usage, exists := base.UsageOf(output); exists {
+			total.Add(usage)
+		}
+	}
+	return total
+}
+
+// channels returns the channels the run published to, from the channel_id
+// output of publishers
+func (r *Result) channels() []string {
+	var channels []string
+	for _, id := range r.order {
+		if channel, ok := r.Outputs[id]["channel_id"].(string); ok && channel != "" && !slices.Contains(channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// usageRecords returns the ledger entries of the nodes that ran, leaving out
+// the outputs reused from a resumed run
+func (r *Result) usageRecords(at time.Time) []UsageRecord {
+	usage := r.Usage()
+	var records []UsageRecord
+	for _, id := range r.order {
+		nodeUsage, exists := usage[id]
+		if !exists || r.Nodes[id] == nil || r.Nodes[id].Reused {
+			continue
+		}
+		records = append(records, UsageRecord{
+			Time:     at,
+			Pipeline: r.Pipeline,
+			RunID:    r.RunID,
+			Node:     id,
+			Channels: r.channels(),
+			DryRun:   r.DryRun,
+			Usage:    nodeUsage,
+		})
+	}
+	return records
+}
+
+// ledgerPath returns the usage ledger of the store
+func (s *RunStore) ledgerPath() string {
+	return filepath.Join(s.dir, UsageLedgerFile)
+}
+
+// RecordUsage appends records to the usage ledger
+func (s *RunStore) RecordUsage(records []UsageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create runs directory: %w", err)
+	}
+
+	var data []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	file, err := os.OpenFile(s.ledgerPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	// A single write keeps the records of concurrent runs on lines of their own
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// UsageRecords reads the usage ledger, which is empty before the first
+// recorded call
+func (s *RunStore) UsageRecords() ([]UsageRecord, error) {
+	file, err := os.Open(s.ledgerPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid usage record: %w", s.ledgerPath(), line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Usage report groupings
+const (
+	UsageByChannel    = "channel"
+	UsageByPipeline   = "pipeline"
+	UsageByCredential = "credential"
+	UsageByModel      = "model"
+)
+
+// UsageGroupings are the ways usage can be reported
+var UsageGroupings = []string{UsageByChannel, UsageByPipeline, UsageByCredential, UsageByModel}
+
+// UsageSummary is a line of a usage report: the usage of a group in a month
+type UsageSummary struct {
+	// Month is formatted as 2006-01
+	Month string
+	Group string
+	Calls int
+	base.Usage
+}
+
+// noGroup names the records without a value to group by, e.g. the runs
+// that published nothing
+const noGroup = "-"
+
+// SummarizeUsage sums records by month (in the local time zone) and group.
+// The tokens and the cost of a run publishing to several channels are split
+// evenly among them, so the report adds up to what was spent.
+func SummarizeUsage(records []UsageRecord, by string) ([]UsageSummary, error) {
+	if !slices.Contains(UsageGroupings, by) {
+		return nil, fmt.Errorf("unknown usage grouping %q (expected one of %s)", by, strings.Join(UsageGroupings, ", "))
+	}
+
+	summaries := make(map[[2]string]*UsageSummary)
+	add := func(month, group string, usage base.Usage, calls int) {
+		key := [2]string{month, group}
+		summary, exists := summaries[key]
+		if !exists {
+			summary = &UsageSummary{Month: month, Group: group}
+			summaries[key] = summary
+		}
+		summary.Calls += calls
+		summary.Add(usage)
+	}
+
+	for _, record := range records {
+		month := record.Time.Local().Format("2006-01")
+		switch by {
+		case UsageByChannel:
+			if len(record.Channels) == 0 {
+				add(month, noGroup, record.Usage, 1)
+				continue
+			}
+			for i, channel := range record.Channels {
+				add(month, channel, splitUsage(record.Usage, len(record.Channels), i), 1)
+			}
+		case UsageByPipeline:
+			add(month, record.Pipeline, record.Usage, 1)
+		case UsageByCredential:
+			add(month, orNoGroup(record.Credential), record.Usage, 1)
+		case UsageByModel:
+			add(month, orNoGroup(record.Model), record.Usage, 1)
+		}
+	}
+
+	report := make([]UsageSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		report = append(report, *summary)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Month != report[j].Month {
+			return report[i].Month < report[j].Month
+		}
+		return report[i].Group < report[j].Group
+	})
+	return report, nil
+}
+
+// splitUsage returns share i of n of a usage, the first share taking the
+// tokens left over by the division
+func splitUsage(usage base.Usage, n, i int) base.Usage {
+	share := func(tokens int) int {
+		if i == 0 {
+			return tokens/n + tokens%n
+		}
+		return tokens / n
+	}
+	return base.Usage{
+		PromptTokens:     share(usage.PromptTokens),
+		CompletionTokens: share(usage.CompletionTokens),
+		TotalTokens:      share(usage.TotalTokens),
+		Cost:             usage.Cost / float64(n),
+		Unpriced:         usage.Unpriced,
+	}
+}
+
+func orNoGroup(value string) string {
+	if value == "" {
+		return noGroup
+	}
+	return value
+}
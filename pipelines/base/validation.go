@@ -0,0 +1,240 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"automation-chain/nodes/base"
+)
+
+// ValidationError lists every problem found in a pipeline definition
+type ValidationError struct {
+	Pipeline string
+	Problems []error
+}
+
+// newValidationError returns a *ValidationError for the given problems, or
+// nil when there are none
+func newValidationError(pipeline string, problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Pipeline: pipeline, Problems: problems}
+}
+
+// Error lists the problems, one per line
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return fmt.Sprintf("invalid pipeline %s: %v", e.Pipeline, e.Problems[0])
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid pipeline %s: %d problems", e.Pipeline, len(e.Problems))
+	for _, problem := range e.Problems {
+		fmt.Fprintf(&b, "\n  - %v", problem)
+	}
+	return b.String()
+}
+
+// Unwrap returns the problems, so errors.Is and errors.As look through them
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// contractProblems checks that every node receives the input keys its type
+// requires, or those its parameters read when the type derives them. Nodes
+// receive the outputs of their upstream nodes, where only non-optional
+// outputs count, and error handlers receive the input of the node they
+// handle plus the error. Nodes whose type is not registered, or that depend
+// on such a node or on an unknown one, are not checked.
+func (p *Pipeline) contractProblems() []error {
+	types := make(map[string]base.NodeType, len(p.nodes))
+	for _, node := range p.nodes {
+		if nodeType, exists := base.NodeTypeOf(node); exists {
+			types[node.Config().ID] = nodeType
+		}
+	}
+
+	// available returns the keys produced by the given nodes, or false when
+	// some of them are unknown
+	available := func(ids []string) (map[string]bool, bool) {
+		keys := make(map[string]bool)
+		for _, id := range ids {
+			nodeType, exists := types[id]
+			if !exists || nodeType.Outputs == nil {
+				return nil, false
+			}
+			for _, output := range nodeType.Outputs {
+				if !output.Optional {
+					keys[output.Name] = true
+				}
+			}
+		}
+		return keys, true
+	}
+
+	handlers := p.errorHandlers()
+	var problems []error
+	for _, node := range p.nodes {
+		id := node.Config().ID
+		nodeType, exists := types[id]
+		if !exists {
+			continue
+		}
+		inputs := nodeType.Inputs
+		if nodeType.InputsFor != nil {
+			inputs = nodeType.InputsFor(node.Config().Parameters)
+		}
+		if len(inputs) == 0 {
+			continue
+		}
+
+		// Error handlers are checked against every node routed to them
+		sources := map[string][]string{"": p.upstreamOf(id)}
+		extra := map[string]bool{}
+		if handlers[id] {
+			sources = make(map[string][]string)
+			extra[base.ErrorKey] = true
+			for _, other := range p.nodes {
+				options := p.options[other.Config().ID]
+				if options.OnError == base.OnErrorRoute && options.ErrorHandler == id {
+					sources[other.Config().ID] = p.upstreamOf(other.Config().ID)
+				}
+			}
+		}
+
+		for _, routed := range sortedKeys(sources) {
+			upstream := sources[routed]
+			keys, known := available(upstream)
+			if !known {
+				continue
+			}
+
+			for _, input := range inputs {
+				key := input.Name
+				if input.Optional || keys[key] || extra[key] {
+					continue
+				}
+
+				problem := fmt.Sprintf("node %s: %s expects %q", id, nodeType.Type, key)
+				if routed != "" {
+					problem += fmt.Sprintf(" when handling errors of %s", routed)
+				}
+				if len(upstream) == 0 {
+					problem += " but has no upstream node producing it"
+				} else {
+					problem += fmt.Sprintf(" but none of the upstream nodes (%s) produces it", strings.Join(upstream, ", "))
+				}
+				problems = append(problems, errors.New(problem))
+			}
+		}
+	}
+
+	return problems
+}
+
+// upstreamOf returns the nodes a node depends on, directly or not, in the
+// order they were added; unknown ones come last. Unlike graph.ancestors it
+// works on pipelines whose graph is invalid.
+func (p *Pipeline) upstreamOf(id string) []string {
+	seen := make(map[string]bool)
+	var collect func(string)
+	collect = func(current string) {
+		for _, dep := range p.options[current].DependsOn {
+			if !seen[dep] {
+				seen[dep] = true
+				collect(dep)
+			}
+		}
+	}
+	collect(id)
+
+	upstream := make([]string, 0, len(seen))
+	for _, node := range p.nodes {
+		if other := node.Config().ID; seen[other] {
+			upstream = append(upstream, other)
+			delete(seen, other)
+		}
+	}
+	return append(upstream, sortedKeys(seen)...)
+}
+
+// inputPathProblems checks that the input paths read by the parameters of a
+// node, such as a history, name upstream nodes when they are under NodesKey.
+// Error handlers are checked against the upstream nodes of every node routed
+// to them.
+func (p *Pipeline) inputPathProblems() []error {
+	g, err := p.graph()
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		ids = append(ids, node.Config().ID)
+	}
+
+	handlers := p.errorHandlers()
+	var problems []error
+	for _, node := range p.nodes {
+		nodeType, exists := base.NodeTypeOf(node)
+		if !exists || nodeType.InputPaths == nil {
+			continue
+		}
+		id := node.Config().ID
+		paths := nodeType.InputPaths(node.Config().Parameters)
+
+		sources := []string{id}
+		if handlers[id] {
+			sources = nil
+			for _, other := range p.nodes {
+				options := p.options[other.Config().ID]
+				if options.OnError == base.OnErrorRoute && options.ErrorHandler == id {
+					sources = append(sources, other.Config().ID)
+				}
+			}
+		}
+
+		for _, param := range sortedKeys(paths) {
+			path := paths[param]
+			keys := strings.Split(path, ".")
+			if len(keys) < 2 || keys[0] != base.NodesKey {
+				continue
+			}
+			root := keys[1]
+
+			if !slices.Contains(ids, root) {
+				problem := fmt.Sprintf("node %s: %s %q names unknown node %s", id, param, path, root)
+				if suggestion := base.Suggest(root, ids); suggestion != "" {
+					problem += fmt.Sprintf("; did you mean %q?", suggestion)
+				}
+				problems = append(problems, errors.New(problem))
+				continue
+			}
+			for _, source := range sources {
+				if !slices.Contains(g.ancestors(source), root) {
+					problem := fmt.Sprintf("node %s: %s %q names node %s, which is not upstream of", id, param, path, root)
+					if source == id {
+						problem += " it"
+					} else {
+						problem += " " + source + ", whose errors it handles"
+					}
+					problems = append(problems, errors.New(problem))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
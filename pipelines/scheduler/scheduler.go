@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+// parser accepts standard five-field cron expressions, an optional leading
+// seconds field and descriptors such as @daily or @every 1h
+var parser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// entry is a scheduled pipeline
+type entry struct {
+	id       cron.EntryID
+	location *time.Location
+}
+
+// RunFunc executes a pipeline
+type RunFunc func(ctx context.Context, config *pipelinebase.PipelineConfig) error
+
+// Scheduler runs pipelines on their cron schedules
+type Scheduler struct {
+	cron    *cron.Cron
+	run     RunFunc
+	entries map[string]entry
+
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+}
+
+// New creates a scheduler that executes pipelines with the given function
+func New(run RunFunc) *Scheduler {
+	runCtx, cancelRun := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		cron: cron.New(
+			cron.WithParser(parser),
+			cron.WithChain(cron.Recover(cron.DefaultLogger)),
+		),
+		run:       run,
+		entries:   make(map[string]entry),
+		runCtx:    runCtx,
+		cancelRun: cancelRun,
+	}
+}
+
+// ParseSchedule parses a cron expression, evaluated in the given timezone
+// (an IANA name such as "Europe/Madrid") or in local time when empty
+func ParseSchedule(spec, timezone string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	if timezone != "" {
+		if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+			return nil, fmt.Errorf("schedule %q already sets a timezone", spec)
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		spec = "CRON_TZ=" + timezone + " " + spec
+	}
+
+	schedule, err := parser.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+
+	return schedule, nil
+}
+
+// Add schedules a pipeline. A pipeline that is still running when its next
+// run is due skips that run.
+func (s *Scheduler) Add(config *pipelinebase.PipelineConfig) error {
+	if _, exists := s.entries[config.Name]; exists {
+		return fmt.Errorf("pipeline %s is already scheduled", config.Name)
+	}
+
+	schedule, err := ParseSchedule(config.Schedule, config.Timezone)
+	if err != nil {
+		return fmt.Errorf("pipeline %s: %w", config.Name, err)
+	}
+
+	location := time.Local
+	if config.Timezone != "" {
+		location, _ = time.LoadLocation(config.Timezone)
+	}
+
+	var id cron.EntryID
+	job := cron.NewChain(cron.SkipIfStillRunning(cron.DefaultLogger)).Then(cron.FuncJob(func() {
+		log.Printf("⏰ Scheduled run of pipeline: %s", config.Name)
+
+		if err := s.run(s.runCtx, config); err != nil {
+			log.Printf("❌ Pipeline %s failed: %v", config.Name, err)
+		} else {
+			log.Printf("✅ Pipeline %s completed successfully", config.Name)
+		}
+
+		log.Printf("Next run of pipeline %s: %s", config.Name, formatTime(s.cron.Entry(id).Next, location))
+	}))
+
+	id = s.cron.Schedule(schedule, job)
+	s.entries[config.Name] = entry{id: id, location: location}
+
+	return nil
+}
+
+// Run starts the scheduler and blocks until ctx is done. It then stops
+// scheduling new runs and waits up to gracePeriod for running pipelines to
+// finish before cancelling them.
+func (s *Scheduler) Run(ctx context.Context, gracePeriod time.Duration) {
+	s.cron.Start()
+	log.Printf("Scheduler started with %d pipelines", len(s.entries))
+
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e := s.entries[name]
+		log.Printf("Next run of pipeline %s: %s", name, formatTime(s.cron.Entry(e.id).Next, e.location))
+	}
+
+	<-ctx.Done()
+	log.Println("Shutting down scheduler...")
+
+	stopped := s.cron.Stop()
+	select {
+	case <-stopped.Done():
+	case <-time.After(gracePeriod):
+		log.Printf("Running pipelines did not finish within %s, cancelling them", gracePeriod)
+		s.cancelRun()
+		<-stopped.Done()
+	}
+	s.cancelRun()
+
+	log.Println("Scheduler stopped")
+}
+
+// formatTime formats a planned run time for the logs, in the pipeline timezone
+func formatTime(t time.Time, location *time.Location) string {
+	if t.IsZero() {
+		return "not scheduled"
+	}
+	return t.In(location).Format("2006-01-02 15:04:05 MST")
+}
@@ -0,0 +1,305 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// valueKind is the JSON type of a parsed value
+type valueKind int
+
+const (
+	kindNull valueKind = iota
+	kindBool
+	kindNumber
+	kindString
+	kindArray
+	kindObject
+)
+
+// String returns the JSON Schema name of the kind
+func (k valueKind) String() string {
+	return [...]string{"null", "boolean", "number", "string", "array", "object"}[k]
+}
+
+// value is a parsed JSON value that remembers where it was found
+type value struct {
+	kind   valueKind
+	offset int
+
+	boolean bool
+	number  float64
+	str     string
+	items   []*value
+	members []member
+}
+
+// member is a property of a JSON object
+type member struct {
+	key       string
+	keyOffset int
+	value     *value
+}
+
+// interfaceValue converts the value to what encoding/json would decode
+func (v *value) interfaceValue() interface{} {
+	switch v.kind {
+	case kindBool:
+		return v.boolean
+	case kindNumber:
+		return v.number
+	case kindString:
+		return v.str
+	case kindArray:
+		items := make([]interface{}, len(v.items))
+		for i, item := range v.items {
+			items[i] = item.interfaceValue()
+		}
+		return items
+	case kindObject:
+		members := make(map[string]interface{}, len(v.members))
+		for _, m := range v.members {
+			members[m.key] = m.value.interfaceValue()
+		}
+		return members
+	default:
+		return nil
+	}
+}
+
+// parser reads JSON keeping the offset of every value, which encoding/json
+// doesn't expose
+type parser struct {
+	data []byte
+	pos  int
+}
+
+// syntaxError is a JSON syntax error at an offset
+type syntaxError struct {
+	offset int
+	msg    string
+}
+
+func (e *syntaxError) Error() string {
+	return e.msg
+}
+
+// parse parses a JSON document
+func parse(data []byte) (*value, error) {
+	p := &parser{data: data}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.data) {
+		return nil, p.errorf("unexpected %s after the end of the document", p.describe())
+	}
+	return v, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &syntaxError{offset: p.pos, msg: fmt.Sprintf(format, args...)}
+}
+
+// describe names the character at the current position for error messages
+func (p *parser) describe() string {
+	if p.pos >= len(p.data) {
+		return "end of file"
+	}
+	r, _ := utf8.DecodeRune(p.data[p.pos:])
+	return strconv.QuoteRune(r)
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) value() (*value, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of file")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		offset := p.pos
+		str, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		return &value{kind: kindString, offset: offset, str: str}, nil
+	case c == '-' || c >= '0' && c <= '9':
+		return p.number()
+	default:
+		for _, literal := range []struct {
+			text  string
+			value value
+		}{
+			{"true", value{kind: kindBool, boolean: true}},
+			{"false", value{kind: kindBool}},
+			{"null", value{kind: kindNull}},
+		} {
+			if len(p.data)-p.pos >= len(literal.text) && string(p.data[p.pos:p.pos+len(literal.text)]) == literal.text {
+				v := literal.value
+				v.offset = p.pos
+				p.pos += len(literal.text)
+				return &v, nil
+			}
+		}
+		return nil, p.errorf("unexpected %s, expected a value", p.describe())
+	}
+}
+
+func (p *parser) object() (*value, error) {
+	v := &value{kind: kindObject, offset: p.pos}
+	p.pos++
+
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == '}' {
+		p.pos++
+		return v, nil
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+			return nil, p.errorf("unexpected %s, expected a property name", p.describe())
+		}
+		keyOffset := p.pos
+		key, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range v.members {
+			if m.key == key {
+				return nil, &syntaxError{offset: keyOffset, msg: fmt.Sprintf("duplicate property %q", key)}
+			}
+		}
+
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return nil, p.errorf("unexpected %s, expected ':'", p.describe())
+		}
+		p.pos++
+
+		item, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		v.members = append(v.members, member{key: key, keyOffset: keyOffset, value: item})
+
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == '}' {
+			p.pos++
+			return v, nil
+		}
+		return nil, p.errorf("unexpected %s, expected ',' or '}'", p.describe())
+	}
+}
+
+func (p *parser) array() (*value, error) {
+	v := &value{kind: kindArray, offset: p.pos}
+	p.pos++
+
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == ']' {
+		p.pos++
+		return v, nil
+	}
+
+	for {
+		item, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		v.items = append(v.items, item)
+
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if p.pos < len(p.data) && p.data[p.pos] == ']' {
+			p.pos++
+			return v, nil
+		}
+		return nil, p.errorf("unexpected %s, expected ',' or ']'", p.describe())
+	}
+}
+
+// string reads a string literal, leaving its decoding to encoding/json
+func (p *parser) string() (string, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			var str string
+			if err := json.Unmarshal(p.data[start:p.pos], &str); err != nil {
+				return "", &syntaxError{offset: start, msg: "invalid string literal"}
+			}
+			return str, nil
+		case '\n':
+			return "", p.errorf("unterminated string")
+		default:
+			p.pos++
+		}
+	}
+	return "", &syntaxError{offset: start, msg: "unterminated string"}
+}
+
+func (p *parser) number() (*value, error) {
+	start := p.pos
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E' || c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	var number float64
+	if err := json.Unmarshal(p.data[start:p.pos], &number); err != nil {
+		return nil, &syntaxError{offset: start, msg: fmt.Sprintf("invalid number %s", p.data[start:p.pos])}
+	}
+	return &value{kind: kindNumber, offset: start, number: number}, nil
+}
+
+// position converts a byte offset to a 1-based line and column
+func position(data []byte, offset int) (int, int) {
+	line, column := 1, 1
+	for i := 0; i < offset && i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+		i += size
+	}
+	return line, column
+}
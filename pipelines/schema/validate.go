@@ -0,0 +1,290 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"automation-chain/nodes/base"
+	"automation-chain/pipelines/scheduler"
+)
+
+// Error is a problem found in a file, located by line and column
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	// Path is the location of the offending value in the document, e.g.
+	// nodes[0].config.temperature; it is empty for the document itself
+	Path    string
+	Message string
+}
+
+// Error formats the problem as file:line:column: path: message
+func (e Error) Error() string {
+	location := fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Column)
+	if e.Path == "" {
+		return location + ": " + e.Message
+	}
+	return location + ": " + e.Path + ": " + e.Message
+}
+
+// ValidateFile checks a JSON file against a schema
+func ValidateFile(path string, s *Schema) ([]Error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Validate(path, data, s), nil
+}
+
+// Validate checks a JSON document against a schema. It returns every problem
+// found, in the order they appear in the document; a syntax error is
+// reported as a single problem.
+func Validate(file string, data []byte, s *Schema) []Error {
+	v := &validator{file: file, data: data}
+
+	document, err := parse(data)
+	if err != nil {
+		syntaxErr := err.(*syntaxError)
+		v.report(syntaxErr.offset, "", "invalid JSON: %s", syntaxErr.msg)
+		return v.errors
+	}
+
+	v.check(document, s, "")
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		a, b := v.errors[i], v.errors[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return v.errors
+}
+
+// validator collects the problems of a document
+type validator struct {
+	file   string
+	data   []byte
+	errors []Error
+}
+
+func (v *validator) report(offset int, path, format string, args ...interface{}) {
+	line, column := position(v.data, offset)
+	v.errors = append(v.errors, Error{
+		File:    v.file,
+		Line:    line,
+		Column:  column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// matches reports whether a value is valid against a schema, without
+// reporting anything
+func (v *validator) matches(val *value, s *Schema) bool {
+	probe := &validator{file: v.file, data: v.data}
+	probe.check(val, s, "")
+	return len(probe.errors) == 0
+}
+
+// check validates a value against a schema
+func (v *validator) check(val *value, s *Schema, path string) {
+	if s.closed {
+		v.report(val.offset, path, "not allowed")
+		return
+	}
+
+	if len(s.Type) > 0 && !typeMatches(val, s.Type) {
+		v.report(val.offset, path, "expected %s, got %s", strings.Join(s.Type, " or "), describe(val))
+		return
+	}
+
+	if s.Const != nil && !reflect.DeepEqual(val.interfaceValue(), s.Const) {
+		v.report(val.offset, path, "must be %v", s.Const)
+	}
+	if len(s.Enum) > 0 {
+		v.checkEnum(val, s, path)
+	}
+
+	switch val.kind {
+	case kindString:
+		v.checkString(val, s, path)
+	case kindNumber:
+		if s.Minimum != nil && val.number < *s.Minimum {
+			v.report(val.offset, path, "must be at least %g, got %g", *s.Minimum, val.number)
+		}
+		if s.Maximum != nil && val.number > *s.Maximum {
+			v.report(val.offset, path, "must be at most %g, got %g", *s.Maximum, val.number)
+		}
+	case kindArray:
+		v.checkArray(val, s, path)
+	case kindObject:
+		v.checkObject(val, s, path)
+	}
+
+	for _, sub := range s.AllOf {
+		v.check(val, sub, path)
+	}
+	if s.If != nil && s.Then != nil && v.matches(val, s.If) {
+		v.check(val, s.Then, path)
+	}
+}
+
+func (v *validator) checkEnum(val *value, s *Schema, path string) {
+	actual := val.interfaceValue()
+	names := make([]string, len(s.Enum))
+	for i, allowed := range s.Enum {
+		if reflect.DeepEqual(actual, allowed) {
+			return
+		}
+		names[i] = fmt.Sprint(allowed)
+	}
+
+	message := fmt.Sprintf("invalid value %s (expected one of: %s)", describeValue(val), strings.Join(names, ", "))
+	if val.kind == kindString {
+		if suggestion := base.Suggest(val.str, names); suggestion != "" {
+			message += fmt.Sprintf("; did you mean %q?", suggestion)
+		}
+	}
+	v.report(val.offset, path, "%s", message)
+}
+
+func (v *validator) checkString(val *value, s *Schema, path string) {
+	if s.MinLength != nil && len([]rune(val.str)) < *s.MinLength {
+		if *s.MinLength == 1 {
+			v.report(val.offset, path, "must not be empty")
+		} else {
+			v.report(val.offset, path, "must be at least %d characters long", *s.MinLength)
+		}
+	}
+
+	var err error
+	switch s.Format {
+	case FormatDuration:
+		if _, parseErr := time.ParseDuration(val.str); parseErr != nil {
+			err = fmt.Errorf("invalid duration %q (expected e.g. \"30s\" or \"2m\")", val.str)
+		}
+	case FormatTemplate:
+		_, err = base.ParseTemplate(path[strings.LastIndex(path, ".")+1:], val.str)
+	case FormatCron:
+		_, err = scheduler.ParseSchedule(val.str, "")
+	case FormatTimezone:
+		if _, loadErr := time.LoadLocation(val.str); loadErr != nil {
+			err = fmt.Errorf("unknown timezone %q", val.str)
+		}
+	default:
+		if s.Pattern != "" {
+			if matched, _ := regexp.MatchString(s.Pattern, val.str); !matched {
+				err = fmt.Errorf("%q does not match the pattern %s", val.str, s.Pattern)
+			}
+		}
+	}
+	if err != nil {
+		v.report(val.offset, path, "%v", err)
+	}
+}
+
+func (v *validator) checkArray(val *value, s *Schema, path string) {
+	if s.MinItems != nil && len(val.items) < *s.MinItems {
+		v.report(val.offset, path, "must have at least %d items", *s.MinItems)
+	}
+
+	seen := make(map[string]bool)
+	for i, item := range val.items {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if s.UniqueItems {
+			key := fmt.Sprintf("%#v", item.interfaceValue())
+			if seen[key] {
+				v.report(item.offset, itemPath, "duplicate value %s", describeValue(item))
+			}
+			seen[key] = true
+		}
+		if s.Items != nil {
+			v.check(item, s.Items, itemPath)
+		}
+	}
+}
+
+func (v *validator) checkObject(val *value, s *Schema, path string) {
+	present := make(map[string]bool, len(val.members))
+	for _, m := range val.members {
+		present[m.key] = true
+	}
+	for _, name := range s.Required {
+		if !present[name] {
+			v.report(val.offset, path, "missing required property %q", name)
+		}
+	}
+
+	for _, m := range val.members {
+		memberPath := m.key
+		if path != "" {
+			memberPath = path + "." + m.key
+		}
+
+		if property, exists := s.Properties[m.key]; exists {
+			v.check(m.value, property, memberPath)
+			continue
+		}
+
+		switch {
+		case s.AdditionalProperties == nil:
+		case s.AdditionalProperties.closed:
+			message := fmt.Sprintf("unknown property %q", m.key)
+			if suggestion := base.Suggest(m.key, sortedProperties(s)); suggestion != "" {
+				message += fmt.Sprintf("; did you mean %q?", suggestion)
+			}
+			v.report(m.keyOffset, path, "%s", message)
+		default:
+			v.check(m.value, s.AdditionalProperties, memberPath)
+		}
+	}
+}
+
+// typeMatches reports whether a value has one of the given JSON Schema types
+func typeMatches(val *value, names Types) bool {
+	for _, name := range names {
+		switch {
+		case name == val.kind.String():
+			return true
+		case name == "integer" && val.kind == kindNumber && val.number == math.Trunc(val.number):
+			return true
+		}
+	}
+	return false
+}
+
+// describe names the type of a value for error messages
+func describe(val *value) string {
+	if val.kind == kindNumber || val.kind == kindBool || val.kind == kindString {
+		return val.kind.String() + " " + describeValue(val)
+	}
+	return val.kind.String()
+}
+
+// describeValue formats a scalar value for error messages
+func describeValue(val *value) string {
+	switch val.kind {
+	case kindString:
+		return fmt.Sprintf("%q", val.str)
+	case kindNumber:
+		return fmt.Sprintf("%g", val.number)
+	default:
+		return fmt.Sprint(val.interfaceValue())
+	}
+}
+
+// sortedProperties returns the names of the properties of a schema
+func sortedProperties(s *Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		if !strings.HasPrefix(name, "$") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
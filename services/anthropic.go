@@ -0,0 +1,294 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the Anthropic provider
+const (
+	AnthropicBaseURL = "https://api.anthropic.com"
+	AnthropicModel   = "claude-3-5-haiku-latest"
+	// anthropicVersion is the version of the Messages API requests follow
+	anthropicVersion = "2023-06-01"
+	// anthropicMaxTokens is sent when no maximum is set, the API requiring one
+	anthropicMaxTokens = 1024
+)
+
+// AnthropicService generates text with the Anthropic Messages API
+type AnthropicService struct {
+	client  *http.Client
+	apiKey  string
+	model   string
+	baseURL string
+	http    HTTPSettings
+	ready   bool
+}
+
+// NewAnthropic creates a new Anthropic service
+func NewAnthropic() *AnthropicService {
+	return &AnthropicService{
+		model:   AnthropicModel,
+		baseURL: AnthropicBaseURL,
+	}
+}
+
+// Name returns the provider the service implements
+func (s *AnthropicService) Name() string {
+	return ProviderAnthropic
+}
+
+// LoadConfig loads configuration from map
+func (s *AnthropicService) LoadConfig(config map[string]interface{}) error {
+	credential, err := DecodeLLMCredential(ProviderAnthropic, config)
+	if err != nil {
+		return err
+	}
+	s.apiKey = credential.APIKey
+	if credential.Model != "" {
+		s.model = credential.Model
+	}
+	if credential.BaseURL != "" {
+		s.baseURL = credential.BaseURL
+	}
+	s.http = credential.HTTPSettings
+
+	s.client = s.http.Client(http.DefaultClient)
+	s.ready = true
+
+	return nil
+}
+
+// anthropicMessage is a message of the Messages API
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// anthropicRequest is the body of a Messages API request
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature *float32           `json:"temperature,omitempty"`
+	TopP        *float32           `json:"top_p,omitempty"`
+}
+
+// anthropicResponse is the body of a Messages API response
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Model      string `json:"model"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// Generate generates text using the Messages API, reporting the tokens of
+// the call. The API takes the system prompt apart from the messages, so
+// system messages among them are appended to it. The frequency and presence
+// penalties have no Anthropic equivalent and are ignored.
+func (s *AnthropicService) Generate(ctx context.Context, prompt string, opts GenerateOptions) (*Completion, error) {
+	if !s.ready {
+		return nil, fmt.Errorf("Anthropic service not initialized")
+	}
+
+	request := anthropicRequest{
+		Model:       s.model,
+		MaxTokens:   opts.MaxTokens,
+		System:      opts.System,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+	}
+	for _, message := range opts.Messages {
+		if message.Role == RoleSystem {
+			request.System = strings.TrimSpace(request.System + "\n\n" + message.Content)
+			continue
+		}
+		request.Messages = appendAnthropicMessage(request.Messages, message.Role, message.Content)
+	}
+	request.Messages = appendAnthropicMessage(request.Messages, RoleUser, prompt)
+	if first := request.Messages[0]; first.Role != RoleUser {
+		return nil, fmt.Errorf("Anthropic conversations must start with a user message, not an %s one", first.Role)
+	}
+	if opts.Model != "" {
+		request.Model = opts.Model
+	}
+	if request.MaxTokens == 0 {
+		request.MaxTokens = anthropicMaxTokens
+	}
+
+	var response anthropicResponse
+	if err := s.do(ctx, http.MethodPost, "/v1/messages", request, &response); err != nil {
+		return nil, anthropicError(err, "failed to generate text")
+	}
+
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no response from Anthropic")
+	}
+
+	completion := &Completion{
+		Text:  text.String(),
+		Model: request.Model,
+		Usage: Usage{
+			PromptTokens:     response.Usage.InputTokens,
+			CompletionTokens: response.Usage.OutputTokens,
+			TotalTokens:      response.Usage.InputTokens + response.Usage.OutputTokens,
+		},
+	}
+	if response.Model != "" {
+		completion.Model = response.Model
+	}
+	return completion, nil
+}
+
+// appendAnthropicMessage adds a message to a conversation. Anthropic rejects
+// conversations whose roles don't alternate, which examples and histories
+// don't guarantee, so a message following one of the same role is merged
+// into it.
+func appendAnthropicMessage(messages []anthropicMessage, role, content string) []anthropicMessage {
+	if last := len(messages) - 1; last >= 0 && messages[last].Role == role {
+		messages[last].Content += "\n\n" + content
+		return messages
+	}
+	return append(messages, anthropicMessage{Role: role, Content: content})
+}
+
+// Check verifies the API key by listing the models it may use
+func (s *AnthropicService) Check(ctx context.Context) (*ModelsCheck, error) {
+	if !s.ready {
+		return nil, fmt.Errorf("Anthropic service not initialized")
+	}
+
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := s.do(ctx, http.MethodGet, "/v1/models?limit=1000", nil, &response); err != nil {
+		return nil, anthropicError(err, "failed to list models")
+	}
+
+	check := &ModelsCheck{Models: len(response.Data), Model: s.model}
+	for _, model := range response.Data {
+		if model.ID == s.model {
+			check.ModelAvailable = true
+			break
+		}
+	}
+	return check, nil
+}
+
+// IsReady returns if service is ready
+func (s *AnthropicService) IsReady() bool {
+	return s.ready
+}
+
+// GetModel returns current model
+func (s *AnthropicService) GetModel() string {
+	return s.model
+}
+
+// anthropicAPIError is an error response of the Anthropic API
+type anthropicAPIError struct {
+	StatusCode int
+	// Type is the error type, e.g. overloaded_error
+	Type       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *anthropicAPIError) Error() string {
+	return fmt.Sprintf("%s (%d): %s", e.Type, e.StatusCode, e.Message)
+}
+
+// do sends a request to the API and decodes its JSON response
+func (s *AnthropicService) do(ctx context.Context, method, path string, body, response interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", s.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	if body != nil {
+		req.Header.Set("content-type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		apiErr := &anthropicAPIError{StatusCode: resp.StatusCode, Type: "error", Message: strings.TrimSpace(string(data))}
+		var errorBody struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &errorBody) == nil && errorBody.Error.Type != "" {
+			apiErr.Type, apiErr.Message = errorBody.Error.Type, errorBody.Error.Message
+		}
+		if seconds, err := strconv.Atoi(resp.Header.Get("retry-after")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return apiErr
+	}
+
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+// anthropicError wraps an Anthropic API error into a classified service error
+func anthropicError(err error, message string) error {
+	class := ClassifyError(err)
+	var retryAfter time.Duration
+
+	if apiErr, ok := err.(*anthropicAPIError); ok {
+		class = classifyHTTPStatus(apiErr.StatusCode)
+		retryAfter = apiErr.RetryAfter
+	}
+
+	return &ServiceError{
+		Service:    ProviderAnthropic,
+		Class:      class,
+		RetryAfter: retryAfter,
+		Err:        fmt.Errorf("%s: %w", message, err),
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LLMCredential is a credential of a language model provider. A credential is
+// implemented by the provider named like its credentials section, or by the
+// one its provider field names, e.g. an openai credential for a local server
+// with "provider": "ollama".
+type LLMCredential struct {
+	// Provider implements the credential: anthropic, ollama or openai
+	Provider string
+	// APIKey is required, but by Ollama
+	APIKey string
+	// Model is the default model of the nodes using the credential, if set
+	Model string
+	// BaseURL is the API endpoint, if not the default one of the provider
+	BaseURL string
+	// APIType is "azure" for Azure OpenAI, which needs BaseURL, and
+	// APIVersion and Deployment optionally. Only OpenAI has one.
+	APIType    string
+	APIVersion string
+	Deployment string
+	HTTPSettings
+}
+
+// TelegramCredential is a Telegram bot and the channel it publishes to
+type TelegramCredential struct {
+	Token     string
+	ChannelID string
+	// BaseURL is the Bot API server, if not the Telegram one
+	BaseURL string
+	HTTPSettings
+}
+
+// DecodeLLMCredential reads the fields of a credential of a language model
+// provider section. The providers load their credentials with it, and the
+// config package checks them with it.
+func DecodeLLMCredential(section string, values map[string]interface{}) (LLMCredential, error) {
+	var credential LLMCredential
+	var err error
+	if credential.Provider, err = stringField(values, "provider", false); err != nil {
+		return credential, err
+	}
+	if credential.Provider == "" {
+		credential.Provider = section
+	}
+	if _, exists := llmProviders[credential.Provider]; !exists {
+		return credential, fmt.Errorf("provider must be one of %s", strings.Join(LLMProviders(), ", "))
+	}
+	if credential.APIKey, err = stringField(values, "api_key", credential.Provider != ProviderOllama); err != nil {
+		return credential, err
+	}
+	if credential.Model, err = stringField(values, "model", false); err != nil {
+		return credential, err
+	}
+	if credential.BaseURL, err = stringField(values, "base_url", false); err != nil {
+		return credential, err
+	}
+	credential.BaseURL = strings.TrimSuffix(credential.BaseURL, "/")
+	if credential.APIType, err = stringField(values, "api_type", false); err != nil {
+		return credential, err
+	}
+	switch credential.APIType {
+	case "", OpenAIAPITypeOpenAI:
+	case OpenAIAPITypeAzure:
+		if credential.Provider != ProviderOpenAI {
+			return credential, fmt.Errorf("api_type is only supported by openai")
+		}
+		if credential.BaseURL == "" {
+			return credential, fmt.Errorf("base_url is required for azure (https://<resource>.openai.azure.com)")
+		}
+	default:
+		return credential, fmt.Errorf("api_type must be %s or %s", OpenAIAPITypeOpenAI, OpenAIAPITypeAzure)
+	}
+	if credential.APIVersion, err = stringField(values, "api_version", false); err != nil {
+		return credential, err
+	}
+	if credential.Deployment, err = stringField(values, "deployment", false); err != nil {
+		return credential, err
+	}
+	if credential.HTTPSettings, err = LoadHTTPSettings(values); err != nil {
+		return credential, err
+	}
+	return credential, nil
+}
+
+// DecodeTelegramCredential reads the fields of a Telegram credential
+func DecodeTelegramCredential(values map[string]interface{}) (TelegramCredential, error) {
+	var credential TelegramCredential
+	var err error
+	if credential.Token, err = stringField(values, "token", true); err != nil {
+		return credential, err
+	}
+	if credential.ChannelID, err = stringField(values, "channel_id", true); err != nil {
+		return credential, err
+	}
+	if credential.BaseURL, err = stringField(values, "base_url", false); err != nil {
+		return credential, err
+	}
+	credential.BaseURL = strings.TrimSuffix(credential.BaseURL, "/")
+	if credential.HTTPSettings, err = LoadHTTPSettings(values); err != nil {
+		return credential, err
+	}
+	return credential, nil
+}
+
+// stringField reads a string field of a credential
+func stringField(values map[string]interface{}, field string, required bool) (string, error) {
+	value, exists := values[field]
+	if !exists || value == "" {
+		if required {
+			return "", fmt.Errorf("%s is required", field)
+		}
+		return "", nil
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", field)
+	}
+	return str, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrorClass categorizes service errors so callers can decide how to react,
+// e.g. retrying a rate limit but not a rejected API key
+type ErrorClass string
+
+const (
+	ErrorClassRateLimit      ErrorClass = "rate_limit"
+	ErrorClassAuth           ErrorClass = "auth"
+	ErrorClassNetwork        ErrorClass = "network"
+	ErrorClassServer         ErrorClass = "server"
+	ErrorClassTimeout        ErrorClass = "timeout"
+	ErrorClassInvalidRequest ErrorClass = "invalid_request"
+	// ErrorClassBudget is a call refused because a spend budget is exhausted
+	ErrorClassBudget  ErrorClass = "budget"
+	ErrorClassUnknown ErrorClass = "unknown"
+)
+
+// ErrorClasses lists every error class
+var ErrorClasses = []ErrorClass{
+	ErrorClassRateLimit,
+	ErrorClassAuth,
+	ErrorClassNetwork,
+	ErrorClassServer,
+	ErrorClassTimeout,
+	ErrorClassInvalidRequest,
+	ErrorClassBudget,
+	ErrorClassUnknown,
+}
+
+// IsErrorClass reports whether name is a known error class
+func IsErrorClass(name string) bool {
+	for _, class := range ErrorClasses {
+		if string(class) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceError is an error returned by an external service, tagged with its class
+type ServiceError struct {
+	Service string
+	Class   ErrorClass
+	// RetryAfter is the delay requested by the service before retrying, if any
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error returns the message of the wrapped error
+func (e *ServiceError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *ServiceError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError returns the class of an error, looking through wrapped
+// errors. It returns an empty class for a nil error.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+
+	var serviceErr *ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Class
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	}
+
+	return ErrorClassUnknown
+}
+
+// RetryAfter returns the delay a service asked to wait before retrying, or zero
+func RetryAfter(err error) time.Duration {
+	var serviceErr *ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr.RetryAfter
+	}
+	return 0
+}
+
+// classifyHTTPStatus maps an HTTP status code to an error class
+func classifyHTTPStatus(code int) ErrorClass {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrorClassRateLimit
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrorClassAuth
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+		return ErrorClassTimeout
+	case code >= 500:
+		return ErrorClassServer
+	case code >= 400:
+		return ErrorClassInvalidRequest
+	default:
+		return ErrorClassUnknown
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTPSettings configures the HTTP client of a service, from the optional
+// proxy and timeout fields of its credential
+type HTTPSettings struct {
+	// Proxy is the URL of the proxy requests go through. Without it the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables apply.
+	Proxy string
+	// Timeout bounds every request, zero leaving the service default
+	Timeout time.Duration
+}
+
+// LoadHTTPSettings reads the HTTP settings of a credential. The services and
+// the typed credentials of the config package share it.
+func LoadHTTPSettings(config map[string]interface{}) (HTTPSettings, error) {
+	var settings HTTPSettings
+
+	if proxy, exists := config["proxy"]; exists {
+		proxyStr, ok := proxy.(string)
+		if !ok {
+			return settings, fmt.Errorf("proxy must be a string")
+		}
+		if proxyStr != "" {
+			if u, err := url.Parse(proxyStr); err != nil || u.Scheme == "" || u.Host == "" {
+				return settings, fmt.Errorf("proxy must be a URL, e.g. http://host:port")
+			}
+		}
+		settings.Proxy = proxyStr
+	}
+
+	if timeout, exists := config["timeout"]; exists {
+		duration, err := ParseTimeout(timeout)
+		if err != nil {
+			return settings, err
+		}
+		settings.Timeout = duration
+	}
+
+	return settings, nil
+}
+
+// Client returns an HTTP client applying the settings, or fallback when
+// there is nothing to apply
+func (s HTTPSettings) Client(fallback *http.Client) *http.Client {
+	if s.Proxy == "" && s.Timeout == 0 {
+		return fallback
+	}
+
+	client := &http.Client{Timeout: s.Timeout}
+	if fallback != nil && s.Timeout == 0 {
+		client.Timeout = fallback.Timeout
+	}
+	if s.Proxy != "" {
+		// LoadHTTPSettings checked the URL
+		proxy, _ := url.Parse(s.Proxy)
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxy)
+		client.Transport = transport
+	}
+	return client
+}
+
+// ParseTimeout reads a timeout given as a Go duration string ("30s") or a
+// number of seconds
+func ParseTimeout(value interface{}) (time.Duration, error) {
+	var duration time.Duration
+	switch v := value.(type) {
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("timeout must be a duration, e.g. 30s, got %q", v)
+		}
+		duration = d
+	case float64:
+		duration = time.Duration(v * float64(time.Second))
+	case int:
+		duration = time.Duration(v) * time.Second
+	default:
+		return 0, fmt.Errorf("timeout must be a duration, e.g. 30s")
+	}
+
+	if duration < 0 {
+		return 0, fmt.Errorf("timeout must not be negative")
+	}
+	return duration, nil
+}
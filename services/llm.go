@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Language model providers
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+// LLMProvider generates text with the language models of a provider
+type LLMProvider interface {
+	// Name returns the name of the provider, e.g. "anthropic"
+	Name() string
+	// LoadConfig loads the provider settings from the fields of a credential
+	LoadConfig(config map[string]interface{}) error
+	// Generate generates text for a prompt, reporting the model that
+	// answered and the tokens of the call
+	Generate(ctx context.Context, prompt string, opts GenerateOptions) (*Completion, error)
+	// Check verifies the credential by listing the models it may use
+	Check(ctx context.Context) (*ModelsCheck, error)
+	// IsReady returns if the provider is configured
+	IsReady() bool
+	// GetModel returns the default model
+	GetModel() string
+}
+
+// Roles of chat messages
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is a chat message
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// GenerateOptions holds per-request generation settings. Zero values leave
+// the provider defaults in place, except for Temperature and TopP which are
+// only sent when set. Providers ignore the settings they don't support.
+type GenerateOptions struct {
+	// System is the system prompt, sent before every message
+	System string
+	// Messages are sent before the prompt, e.g. few-shot examples and the
+	// conversation so far
+	Messages         []Message
+	Model            string
+	MaxTokens        int
+	Temperature      *float32
+	TopP             *float32
+	FrequencyPenalty float32
+	PresencePenalty  float32
+}
+
+// Usage is the number of tokens of a call
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Completion is the text a model generated
+type Completion struct {
+	Text string
+	// Model is the model that answered, which may be a snapshot of the
+	// requested one
+	Model string
+	Usage Usage
+}
+
+// ModelsCheck is what a health check learned about a credential
+type ModelsCheck struct {
+	// Models is the number of models the credential may use
+	Models int
+	// Model is the default model of the provider
+	Model string
+	// ModelAvailable reports whether Model is among the models
+	ModelAvailable bool
+}
+
+// llmProviders create the providers by name
+var llmProviders = map[string]func() LLMProvider{
+	ProviderOpenAI:    func() LLMProvider { return NewOpenAI() },
+	ProviderAnthropic: func() LLMProvider { return NewAnthropic() },
+	ProviderOllama:    func() LLMProvider { return NewOllama() },
+}
+
+// NewLLMProvider creates an unconfigured provider by name
+func NewLLMProvider(name string) (LLMProvider, error) {
+	newProvider, exists := llmProviders[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown LLM provider %q (expected one of %v)", name, LLMProviders())
+	}
+	return newProvider(), nil
+}
+
+// LLMProviders returns the names of the providers, sorted
+func LLMProviders() []string {
+	names := make([]string, 0, len(llmProviders))
+	for name := range llmProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	_ LLMProvider = (*OpenAIService)(nil)
+	_ LLMProvider = (*AnthropicService)(nil)
+)
@@ -1,47 +1,94 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
 
 	"github.com/sashabaranov/go-openai"
 )
 
-// OpenAIService handles OpenAI operations
+// OpenAI API types: the OpenAI API, or an Azure OpenAI resource
+const (
+	OpenAIAPITypeOpenAI = "openai"
+	OpenAIAPITypeAzure  = "azure"
+)
+
+// Defaults of the Ollama provider, a local OpenAI-compatible server
+const (
+	OllamaBaseURL = "http://localhost:11434/v1"
+	OllamaModel   = "llama3"
+)
+
+// OpenAIService handles OpenAI operations. Besides the OpenAI API it can
+// use Azure OpenAI and OpenAI-compatible servers (llama.cpp, vLLM, Ollama).
 type OpenAIService struct {
-	client *openai.Client
-	apiKey string
-	model  string
-	ready  bool
+	// name is the provider the service implements, openai or ollama
+	name    string
+	client  *openai.Client
+	apiKey  string
+	model   string
+	baseURL string
+	// apiType, apiVersion and deployment configure Azure OpenAI
+	apiType    string
+	apiVersion string
+	deployment string
+	http       HTTPSettings
+	ready      bool
 }
 
 // NewOpenAI creates a new OpenAI service
 func NewOpenAI() *OpenAIService {
 	return &OpenAIService{
-		model: "gpt-3.5-turbo", // default
+		name:    ProviderOpenAI,
+		model:   "gpt-3.5-turbo", // default
+		apiType: OpenAIAPITypeOpenAI,
+	}
+}
+
+// NewOllama creates a service for a local Ollama server, which needs no API
+// key. Its base_url can point at any OpenAI-compatible server instead.
+func NewOllama() *OpenAIService {
+	return &OpenAIService{
+		name:    ProviderOllama,
+		apiKey:  "ollama",
+		model:   OllamaModel,
+		baseURL: OllamaBaseURL,
+		apiType: OpenAIAPITypeOpenAI,
 	}
 }
 
+// Name returns the provider the service implements
+func (s *OpenAIService) Name() string {
+	return s.name
+}
+
 // LoadConfig loads configuration from map
 func (s *OpenAIService) LoadConfig(config map[string]interface{}) error {
-	// Extract API key
-	if apiKey, exists := config["api_key"]; exists {
-		if apiKeyStr, ok := apiKey.(string); ok {
-			s.apiKey = apiKeyStr
-		} else {
-			return fmt.Errorf("invalid API key format")
-		}
-	} else {
-		return fmt.Errorf("API key is required")
+	credential, err := DecodeLLMCredential(s.name, config)
+	if err != nil {
+		return err
 	}
-
-	// Extract model (optional)
-	if model, exists := config["model"]; exists {
-		if modelStr, ok := model.(string); ok {
-			s.model = modelStr
-		}
+	if credential.APIKey != "" {
+		s.apiKey = credential.APIKey
+	}
+	if credential.Model != "" {
+		s.model = credential.Model
+	}
+	if credential.BaseURL != "" {
+		s.baseURL = credential.BaseURL
 	}
+	if credential.APIType != "" {
+		s.apiType = credential.APIType
+	}
+	s.apiVersion = credential.APIVersion
+	s.deployment = credential.Deployment
+	s.http = credential.HTTPSettings
 
 	// Validate
 	if err := s.validate(); err != nil {
@@ -49,18 +96,42 @@ func (s *OpenAIService) LoadConfig(config map[string]interface{}) error {
 	}
 
 	// Create client
-	s.client = openai.NewClient(s.apiKey)
+	var clientConfig openai.ClientConfig
+	if s.apiType == OpenAIAPITypeAzure {
+		clientConfig = openai.DefaultAzureConfig(s.apiKey, s.baseURL)
+		if s.apiVersion != "" {
+			clientConfig.APIVersion = s.apiVersion
+		}
+		if s.deployment != "" {
+			clientConfig.AzureModelMapperFunc = func(string) string { return s.deployment }
+		}
+	} else {
+		clientConfig = openai.DefaultConfig(s.apiKey)
+		if s.baseURL != "" {
+			clientConfig.BaseURL = s.baseURL
+		}
+	}
+	httpClient := *s.http.Client(clientConfig.HTTPClient)
+	httpClient.Transport = explicitZeroTransport{base: httpClient.Transport}
+	clientConfig.HTTPClient = &httpClient
+	s.client = openai.NewClientWithConfig(clientConfig)
 	s.ready = true
 
 	return nil
 }
 
-// validate checks if configuration is valid
+// validate checks if configuration is valid; DecodeLLMCredential checked
+// the fields
 func (s *OpenAIService) validate() error {
 	if s.apiKey == "" {
 		return fmt.Errorf("API key is required")
 	}
 
+	// Other servers have keys of their own, if any (e.g. Ollama ignores it)
+	if s.baseURL != "" {
+		return nil
+	}
+
 	if !strings.HasPrefix(s.apiKey, "sk-") {
 		return fmt.Errorf("invalid API key format")
 	}
@@ -72,31 +143,150 @@ func (s *OpenAIService) validate() error {
 	return nil
 }
 
-// GenerateText generates text using OpenAI
-func (s *OpenAIService) GenerateText(ctx context.Context, prompt string) (string, error) {
+// Generate generates text using OpenAI, reporting the tokens of the call
+func (s *OpenAIService) Generate(ctx context.Context, prompt string, opts GenerateOptions) (*Completion, error) {
 	if !s.ready {
-		return "", fmt.Errorf("OpenAI service not initialized")
+		return nil, fmt.Errorf("OpenAI service not initialized")
 	}
 
-	resp, err := s.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: s.model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
+	model := s.model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+
+	var messages []openai.ChatCompletionMessage
+	if opts.System != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: opts.System})
+	}
+	for _, message := range opts.Messages {
+		messages = append(messages, openai.ChatCompletionMessage{Role: message.Role, Content: message.Content})
+	}
+	messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: prompt})
+
+	// The request type omits zero temperatures and top_p values, which the
+	// transport of the client adds back when they are set explicitly
+	resp, err := s.client.CreateChatCompletion(withExplicitZeros(ctx, opts), openai.ChatCompletionRequest{
+		Model:            model,
+		Messages:         messages,
+		MaxTokens:        opts.MaxTokens,
+		Temperature:      floatSetting(opts.Temperature),
+		TopP:             floatSetting(opts.TopP),
+		FrequencyPenalty: opts.FrequencyPenalty,
+		PresencePenalty:  opts.PresencePenalty,
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("failed to generate text: %w", err)
+		return nil, openAIError(err, "failed to generate text")
 	}
 
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response from OpenAI")
+		return nil, fmt.Errorf("no response from OpenAI")
+	}
+
+	completion := &Completion{
+		Text:  resp.Choices[0].Message.Content,
+		Model: model,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}
+	// The API names the model snapshot that answered, e.g. gpt-4o-2024-08-06
+	if resp.Model != "" {
+		completion.Model = resp.Model
+	}
+	return completion, nil
+}
+
+// floatSetting returns the value of an optional setting, zero when unset
+func floatSetting(value *float32) float32 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+// explicitZerosKey is the context key of the request fields set to zero
+type explicitZerosKey struct{}
+
+// withExplicitZeros marks the optional settings set to zero in the context
+// of a chat completion request
+func withExplicitZeros(ctx context.Context, opts GenerateOptions) context.Context {
+	var fields []string
+	if opts.Temperature != nil && *opts.Temperature == 0 {
+		fields = append(fields, "temperature")
+	}
+	if opts.TopP != nil && *opts.TopP == 0 {
+		fields = append(fields, "top_p")
+	}
+	if len(fields) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, explicitZerosKey{}, fields)
+}
+
+// explicitZeroTransport writes the fields marked by withExplicitZeros into
+// the JSON body of a request. The client tags temperature and top_p with
+// omitempty, so a zero would otherwise leave the API default in place.
+type explicitZeroTransport struct {
+	base http.RoundTripper
+}
+
+func (t explicitZeroTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	fields, _ := r.Context().Value(explicitZerosKey{}).([]string)
+	if len(fields) == 0 || r.Body == nil {
+		return base.RoundTrip(r)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		request[field] = json.RawMessage("0")
+	}
+	if body, err = json.Marshal(request); err != nil {
+		return nil, err
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	r = r.Clone(r.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.ContentLength = int64(len(body))
+	return base.RoundTrip(r)
+}
+
+// Check verifies the API key by listing the models it may use
+func (s *OpenAIService) Check(ctx context.Context) (*ModelsCheck, error) {
+	if !s.ready {
+		return nil, fmt.Errorf("OpenAI service not initialized")
+	}
+
+	models, err := s.client.ListModels(ctx)
+	if err != nil {
+		return nil, openAIError(err, "failed to list models")
+	}
+
+	check := &ModelsCheck{Models: len(models.Models), Model: s.model}
+	for _, model := range models.Models {
+		if model.ID == s.model {
+			check.ModelAvailable = true
+			break
+		}
+	}
+	return check, nil
 }
 
 // IsReady returns if service is ready
@@ -107,4 +297,27 @@ func (s *OpenAIService) IsReady() bool {
 // GetModel returns current model
 func (s *OpenAIService) GetModel() string {
 	return s.model
-} 
\ No newline at end of file
+}
+
+// openAIError wraps an OpenAI client error into a classified service error
+func openAIError(err error, message string) error {
+	class := ClassifyError(err)
+
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == "insufficient_quota":
+		// Reported as a 429, but retrying won't help until billing is fixed
+		class = ErrorClassAuth
+	case errors.As(err, &apiErr) && apiErr.HTTPStatusCode > 0:
+		class = classifyHTTPStatus(apiErr.HTTPStatusCode)
+	case errors.As(err, &requestErr) && requestErr.HTTPStatusCode > 0:
+		class = classifyHTTPStatus(requestErr.HTTPStatusCode)
+	}
+
+	return &ServiceError{
+		Service: "openai",
+		Class:   class,
+		Err:     fmt.Errorf("%s: %w", message, err),
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultPricesFile is the price table layered over DefaultPrices, if it exists
+const DefaultPricesFile = "config/prices.json"
+
+// Price is the price of a model, in US dollars per million tokens
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// PriceTable holds the prices of models by name. A model without an entry
+// of its own takes the price of the longest name it starts with, so
+// gpt-4o-2024-08-06 costs what gpt-4o does.
+type PriceTable map[string]Price
+
+// DefaultPrices are the list prices of common models. Local models are free.
+var DefaultPrices = PriceTable{
+	"gpt-3.5-turbo":     {Prompt: 0.5, Completion: 1.5},
+	"gpt-4":             {Prompt: 30, Completion: 60},
+	"gpt-4-turbo":       {Prompt: 10, Completion: 30},
+	"gpt-4o":            {Prompt: 2.5, Completion: 10},
+	"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.6},
+	"claude-3-haiku":    {Prompt: 0.25, Completion: 1.25},
+	"claude-3-5-haiku":  {Prompt: 0.8, Completion: 4},
+	"claude-3-5-sonnet": {Prompt: 3, Completion: 15},
+	"claude-3-7-sonnet": {Prompt: 3, Completion: 15},
+	"claude-3-opus":     {Prompt: 15, Completion: 75},
+}
+
+// Lookup returns the price of a model
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	if price, exists := t[model]; exists {
+		return price, true
+	}
+
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return t[best], true
+}
+
+// Cost returns the cost of a call in US dollars, and false when the model
+// has no price
+func (t PriceTable) Cost(model string, usage Usage) (float64, bool) {
+	price, exists := t.Lookup(model)
+	if !exists {
+		return 0, false
+	}
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6, true
+}
+
+// LoadPrices reads a price table file, {"model": {"prompt": 2.5,
+// "completion": 10}}, over DefaultPrices. A missing file leaves the
+// defaults; a model priced at zero is free, e.g. a local one.
+func LoadPrices(path string) (PriceTable, error) {
+	table := make(PriceTable, len(DefaultPrices))
+	for model, price := range DefaultPrices {
+		table[model] = price
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return table, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var prices PriceTable
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for model, price := range prices {
+		if price.Prompt < 0 || price.Completion < 0 {
+			return nil, fmt.Errorf("%s: price of %s must not be negative", path, model)
+		}
+		table[model] = price
+	}
+	return table, nil
+}
+
+var (
+	pricesMu sync.RWMutex
+	prices   = DefaultPrices
+)
+
+// Prices returns the price table calls are priced with
+func Prices() PriceTable {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+	return prices
+}
+
+// SetPrices replaces the price table calls are priced with
+func SetPrices(table PriceTable) {
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+	prices = table
+}
@@ -2,9 +2,14 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/telebot.v3"
 )
@@ -14,6 +19,8 @@ type TelegramService struct {
 	bot       *telebot.Bot
 	token     string
 	channelID string
+	baseURL   string
+	http      HTTPSettings
 	ready     bool
 }
 
@@ -24,39 +31,31 @@ func NewTelegram() *TelegramService {
 
 // LoadConfig loads configuration from map
 func (s *TelegramService) LoadConfig(config map[string]interface{}) error {
-	// Extract token
-	if token, exists := config["token"]; exists {
-		if tokenStr, ok := token.(string); ok {
-			s.token = tokenStr
-		} else {
-			return fmt.Errorf("invalid token format")
-		}
-	} else {
-		return fmt.Errorf("token is required")
-	}
-
-	// Extract channel ID
-	if channelID, exists := config["channel_id"]; exists {
-		if channelIDStr, ok := channelID.(string); ok {
-			s.channelID = channelIDStr
-		} else {
-			return fmt.Errorf("invalid channel ID format")
-		}
-	} else {
-		return fmt.Errorf("channel ID is required")
+	credential, err := DecodeTelegramCredential(config)
+	if err != nil {
+		return err
 	}
+	s.token = credential.Token
+	s.channelID = credential.ChannelID
+	s.baseURL = credential.BaseURL
+	s.http = credential.HTTPSettings
 
 	// Validate
 	if err := s.validate(); err != nil {
 		return err
 	}
 
-	// Create bot
+	// Create bot. It is created offline so building a pipeline, or running
+	// it dry, never calls the Telegram API; an invalid token is reported
+	// when the first message is sent.
 	bot, err := telebot.NewBot(telebot.Settings{
-		Token: s.token,
+		Token:   s.token,
+		URL:     s.baseURL,
+		Client:  s.http.Client(&http.Client{Timeout: time.Minute}),
+		Offline: true,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create bot: %w", err)
+		return telegramError(err, "failed to create bot")
 	}
 
 	s.bot = bot
@@ -65,20 +64,13 @@ func (s *TelegramService) LoadConfig(config map[string]interface{}) error {
 	return nil
 }
 
-// validate checks if configuration is valid
+// validate checks if configuration is valid; DecodeTelegramCredential
+// checked the fields
 func (s *TelegramService) validate() error {
-	if s.token == "" {
-		return fmt.Errorf("token is required")
-	}
-
 	if len(s.token) < 30 {
 		return fmt.Errorf("token too short")
 	}
 
-	if s.channelID == "" {
-		return fmt.Errorf("channel ID is required")
-	}
-
 	if len(s.channelID) < 3 {
 		return fmt.Errorf("channel ID too short")
 	}
@@ -86,8 +78,9 @@ func (s *TelegramService) validate() error {
 	return nil
 }
 
-// SendMessage sends a message to the configured channel
-func (s *TelegramService) SendMessage(ctx context.Context, text string) error {
+// SendMessage sends a message to the configured channel, formatted with the
+// given parse mode (TelegramParseModeNone sends it as is)
+func (s *TelegramService) SendMessage(ctx context.Context, text string, parseMode string) error {
 	if !s.ready {
 		return fmt.Errorf("Telegram service not initialized")
 	}
@@ -107,14 +100,124 @@ func (s *TelegramService) SendMessage(ctx context.Context, text string) error {
 	}
 
 	// Send message
-	_, err := s.bot.Send(&chat, text)
+	bot, err := s.botFor(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return telegramError(err, "failed to create bot")
+	}
+	if _, err := bot.Send(&chat, text, telebot.ParseMode(parseMode)); err != nil {
+		return telegramError(err, "failed to send message")
 	}
 
 	return nil
 }
 
+// botFor returns a bot whose requests are cancelled when ctx is done.
+// telebot doesn't take a context, so a message abandoned at a timeout could
+// otherwise still be delivered, and then sent again by a retry.
+func (s *TelegramService) botFor(ctx context.Context) (*telebot.Bot, error) {
+	client := *s.http.Client(&http.Client{Timeout: time.Minute})
+	client.Transport = contextTransport{ctx: ctx, base: client.Transport}
+	return telebot.NewBot(telebot.Settings{
+		Token:   s.token,
+		URL:     s.baseURL,
+		Client:  &client,
+		Offline: true,
+	})
+}
+
+// contextTransport sends requests with the context it is bound to
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r.WithContext(t.ctx))
+}
+
+// TelegramCheck is what a health check learned about a bot and its channel
+type TelegramCheck struct {
+	// Bot is the username of the bot
+	Bot string
+	// Chat is the title of the channel, or its configured ID
+	Chat string
+	// Role is the status of the bot in the channel, e.g. administrator
+	Role string
+	// CanPost reports whether the bot may publish to the channel
+	CanPost bool
+}
+
+// Check verifies the token with getMe and the channel with getChat, and
+// looks up the rights of the bot in the channel. It gives up when ctx is
+// done, although the pending request isn't interrupted.
+func (s *TelegramService) Check(ctx context.Context) (*TelegramCheck, error) {
+	if !s.ready {
+		return nil, fmt.Errorf("Telegram service not initialized")
+	}
+
+	type result struct {
+		check *TelegramCheck
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		check, err := s.check()
+		done <- result{check, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.check, r.err
+	case <-ctx.Done():
+		return nil, &ServiceError{Service: "telegram", Class: ErrorClassTimeout, Err: fmt.Errorf("health check: %w", ctx.Err())}
+	}
+}
+
+func (s *TelegramService) check() (*TelegramCheck, error) {
+	data, err := s.bot.Raw("getMe", nil)
+	if err != nil {
+		return nil, telegramError(err, "getMe failed")
+	}
+	var me struct {
+		Result telebot.User `json:"result"`
+	}
+	if err := json.Unmarshal(data, &me); err != nil {
+		return nil, fmt.Errorf("invalid getMe response: %w", err)
+	}
+
+	chat, err := s.bot.ChatByUsername(s.channelID)
+	if err != nil {
+		return nil, telegramError(err, "getChat failed")
+	}
+	member, err := s.bot.ChatMemberOf(chat, &me.Result)
+	if err != nil {
+		return nil, telegramError(err, "getChatMember failed")
+	}
+
+	check := &TelegramCheck{Bot: "@" + me.Result.Username, Chat: s.channelID, Role: string(member.Role)}
+	if chat.Title != "" {
+		check.Chat = chat.Title
+	}
+
+	// In channels only administrators allowed to post may publish
+	channel := chat.Type == telebot.ChatChannel || chat.Type == telebot.ChatChannelPrivate
+	switch member.Role {
+	case telebot.Creator:
+		check.CanPost = true
+	case telebot.Administrator:
+		check.CanPost = !channel || member.CanPostMessages
+	case telebot.Member:
+		check.CanPost = !channel
+	case telebot.Restricted:
+		check.CanPost = member.CanSendMessages
+	}
+	return check, nil
+}
+
 // IsReady returns if service is ready
 func (s *TelegramService) IsReady() bool {
 	return s.ready
@@ -123,4 +226,38 @@ func (s *TelegramService) IsReady() bool {
 // GetChannelID returns the channel ID
 func (s *TelegramService) GetChannelID() string {
 	return s.channelID
-} 
\ No newline at end of file
+}
+
+// telegramStatusPattern extracts the status code telebot appends to API error messages
+var telegramStatusPattern = regexp.MustCompile(`\((\d{3})\)$`)
+
+// telegramError wraps a telebot error into a classified service error
+func telegramError(err error, message string) error {
+	class := ClassifyError(err)
+	var retryAfter time.Duration
+
+	var floodErr telebot.FloodError
+	var groupErr telebot.GroupError
+	var apiErr *telebot.Error
+	switch {
+	case errors.As(err, &floodErr):
+		class = ErrorClassRateLimit
+		retryAfter = time.Duration(floodErr.RetryAfter) * time.Second
+	case errors.As(err, &groupErr):
+		class = ErrorClassInvalidRequest
+	case errors.As(err, &apiErr):
+		class = classifyHTTPStatus(apiErr.Code)
+	case class == ErrorClassUnknown:
+		if match := telegramStatusPattern.FindStringSubmatch(err.Error()); match != nil {
+			code, _ := strconv.Atoi(match[1])
+			class = classifyHTTPStatus(code)
+		}
+	}
+
+	return &ServiceError{
+		Service:    "telegram",
+		Class:      class,
+		RetryAfter: retryAfter,
+		Err:        fmt.Errorf("%s: %w", message, err),
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// TelegramMaxMessageLength is the maximum length of a message text, counted
+// in UTF-16 code units after the formatting entities are parsed
+const TelegramMaxMessageLength = 4096
+
+// Telegram parse modes. The empty mode sends the text as is.
+const (
+	TelegramParseModeNone       = ""
+	TelegramParseModeMarkdown   = "Markdown"
+	TelegramParseModeMarkdownV2 = "MarkdownV2"
+	TelegramParseModeHTML       = "HTML"
+)
+
+// ValidateTelegramMessage checks that a message would be accepted by
+// Telegram: its formatting must be well formed for the parse mode and its
+// text must not be empty or longer than TelegramMaxMessageLength
+func ValidateTelegramMessage(text, parseMode string) error {
+	plain, err := telegramPlainText(text, parseMode)
+	if err != nil {
+		return invalidTelegramMessage(fmt.Errorf("invalid %s formatting: %w", parseMode, err))
+	}
+
+	if strings.TrimSpace(plain) == "" {
+		return invalidTelegramMessage(fmt.Errorf("message is empty"))
+	}
+	if length := len(utf16.Encode([]rune(plain))); length > TelegramMaxMessageLength {
+		return invalidTelegramMessage(fmt.Errorf("message is %d characters long, Telegram allows %d", length, TelegramMaxMessageLength))
+	}
+
+	return nil
+}
+
+// invalidTelegramMessage tags a message validation error as an invalid request
+func invalidTelegramMessage(err error) error {
+	return &ServiceError{Service: "telegram", Class: ErrorClassInvalidRequest, Err: err}
+}
+
+// telegramPlainText returns the text Telegram displays for a message, with
+// the formatting removed
+func telegramPlainText(text, parseMode string) (string, error) {
+	switch parseMode {
+	case TelegramParseModeHTML:
+		return htmlPlainText(text)
+	case TelegramParseModeMarkdown:
+		return markdownPlainText(text)
+	case TelegramParseModeMarkdownV2:
+		return markdownV2PlainText(text)
+	default:
+		return text, nil
+	}
+}
+
+// telegramHTMLTags are the tags supported by the HTML parse mode
+var telegramHTMLTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "span": true, "tg-spoiler": true,
+	"a": true, "code": true, "pre": true, "blockquote": true, "tg-emoji": true,
+}
+
+// htmlEntityPattern matches the HTML entities supported by Telegram
+var htmlEntityPattern = regexp.MustCompile(`^&(lt|gt|amp|quot|#[0-9]+|#x[0-9a-fA-F]+);`)
+
+// htmlPlainText checks the tags and entities of an HTML message
+func htmlPlainText(text string) (string, error) {
+	var plain strings.Builder
+	var open []string
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '<':
+			end := strings.IndexByte(text[i:], '>')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed tag at offset %d; escape '<' as &lt;", i)
+			}
+			tag := text[i+1 : i+end]
+
+			if strings.HasPrefix(tag, "/") {
+				name := strings.ToLower(strings.TrimSpace(tag[1:]))
+				if len(open) == 0 || open[len(open)-1] != name {
+					return "", fmt.Errorf("unexpected closing tag </%s> at offset %d", name, i)
+				}
+				open = open[:len(open)-1]
+			} else {
+				fields := strings.Fields(tag)
+				if len(fields) == 0 {
+					return "", fmt.Errorf("empty tag at offset %d", i)
+				}
+				name := strings.ToLower(fields[0])
+				if !telegramHTMLTags[name] {
+					return "", fmt.Errorf("unsupported tag <%s> at offset %d", name, i)
+				}
+				open = append(open, name)
+			}
+			i += end + 1
+		case '&':
+			entity := htmlEntityPattern.FindString(text[i:])
+			if entity == "" {
+				return "", fmt.Errorf("unescaped '&' at offset %d; escape it as &amp;", i)
+			}
+			plain.WriteByte('&')
+			i += len(entity)
+		default:
+			plain.WriteByte(text[i])
+			i++
+		}
+	}
+
+	if len(open) > 0 {
+		return "", fmt.Errorf("unclosed tag <%s>", open[len(open)-1])
+	}
+	return plain.String(), nil
+}
+
+// markdownPlainText checks the entities of a legacy Markdown message
+func markdownPlainText(text string) (string, error) {
+	var plain strings.Builder
+
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && strings.IndexByte("_*`[", text[i+1]) >= 0:
+			plain.WriteByte(text[i+1])
+			i += 2
+		case strings.HasPrefix(text[i:], "```"):
+			end := strings.Index(text[i+3:], "```")
+			if end < 0 {
+				return "", fmt.Errorf("unclosed ``` at offset %d", i)
+			}
+			plain.WriteString(text[i+3 : i+3+end])
+			i += end + 6
+		case text[i] == '*' || text[i] == '_' || text[i] == '`':
+			end := strings.IndexByte(text[i+1:], text[i])
+			if end < 0 {
+				return "", fmt.Errorf("unclosed %c at offset %d; escape it with '\\'", text[i], i)
+			}
+			plain.WriteString(text[i+1 : i+1+end])
+			i += end + 2
+		case text[i] == '[':
+			label, length, err := markdownLink(text, i)
+			if err != nil {
+				return "", err
+			}
+			plain.WriteString(label)
+			i += length
+		default:
+			plain.WriteByte(text[i])
+			i++
+		}
+	}
+
+	return plain.String(), nil
+}
+
+// markdownLink parses a [label](url) link starting at offset i, returning
+// its label and length
+func markdownLink(text string, i int) (string, int, error) {
+	closing := strings.IndexByte(text[i:], ']')
+	if closing < 0 || !strings.HasPrefix(text[i+closing+1:], "(") {
+		return "", 0, fmt.Errorf("unclosed link at offset %d; escape '[' with '\\'", i)
+	}
+
+	end := strings.IndexByte(text[i+closing+1:], ')')
+	if end < 0 {
+		return "", 0, fmt.Errorf("unclosed link URL at offset %d", i+closing+1)
+	}
+
+	return text[i+1 : i+closing], closing + end + 2, nil
+}
+
+// markdownV2Reserved are the characters that must be escaped outside entities
+const markdownV2Reserved = "_*[]()~`>#+-=|{}.!"
+
+// markdownV2PlainText checks the entities and escaping of a MarkdownV2 message
+func markdownV2PlainText(text string) (string, error) {
+	var plain strings.Builder
+	var open []string
+
+	toggle := func(marker string) {
+		if len(open) > 0 && open[len(open)-1] == marker {
+			open = open[:len(open)-1]
+		} else {
+			open = append(open, marker)
+		}
+	}
+
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '\\':
+			if i+1 >= len(text) {
+				return "", fmt.Errorf("dangling '\\' at the end of the message")
+			}
+			plain.WriteByte(text[i+1])
+			i += 2
+		case strings.HasPrefix(text[i:], "```"), text[i] == '`':
+			marker := "`"
+			if strings.HasPrefix(text[i:], "```") {
+				marker = "```"
+			}
+			code, length, err := markdownV2Code(text, i, marker)
+			if err != nil {
+				return "", err
+			}
+			plain.WriteString(code)
+			i += length
+		case strings.HasPrefix(text[i:], "||"):
+			toggle("||")
+			i += 2
+		case strings.HasPrefix(text[i:], "__"):
+			toggle("__")
+			i += 2
+		case text[i] == '*' || text[i] == '_' || text[i] == '~':
+			toggle(string(text[i]))
+			i++
+		case text[i] == '[':
+			open = append(open, "[")
+			i++
+		case text[i] == ']':
+			if len(open) == 0 || open[len(open)-1] != "[" {
+				return "", fmt.Errorf("unexpected ']' at offset %d; escape it with '\\'", i)
+			}
+			open = open[:len(open)-1]
+			length, err := markdownV2URL(text, i+1)
+			if err != nil {
+				return "", err
+			}
+			i += 1 + length
+		case text[i] == '>' && (i == 0 || text[i-1] == '\n'):
+			// Block quotation
+			i++
+		case strings.IndexByte(markdownV2Reserved, text[i]) >= 0:
+			return "", fmt.Errorf("character '%c' at offset %d is reserved and must be escaped with '\\'", text[i], i)
+		default:
+			plain.WriteByte(text[i])
+			i++
+		}
+	}
+
+	if len(open) > 0 {
+		return "", fmt.Errorf("unclosed %s entity", open[len(open)-1])
+	}
+	return plain.String(), nil
+}
+
+// markdownV2Code parses a code entity delimited by marker starting at offset
+// i, returning its text and length. Inside code, '\' escapes '`' and '\'.
+func markdownV2Code(text string, i int, marker string) (string, int, error) {
+	var code strings.Builder
+	for j := i + len(marker); j < len(text); j++ {
+		switch {
+		case text[j] == '\\' && j+1 < len(text):
+			code.WriteByte(text[j+1])
+			j++
+		case strings.HasPrefix(text[j:], marker):
+			return code.String(), j + len(marker) - i, nil
+		default:
+			code.WriteByte(text[j])
+		}
+	}
+	return "", 0, fmt.Errorf("unclosed %s at offset %d", marker, i)
+}
+
+// markdownV2URL parses the (url) following a link label at offset i,
+// returning its length
+func markdownV2URL(text string, i int) (int, error) {
+	if !strings.HasPrefix(text[i:], "(") {
+		return 0, fmt.Errorf("link at offset %d has no URL; escape '[' and ']' with '\\'", i)
+	}
+	for j := i + 1; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case ')':
+			return j + 1 - i, nil
+		}
+	}
+	return 0, fmt.Errorf("unclosed link URL at offset %d", i)
+}
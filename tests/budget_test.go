@@ -0,0 +1,303 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/services"
+)
+
+// newAlertStandIn serves the sendMessage method of the Bot API, recording
+// the texts sent
+func newAlertStandIn(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot"+checkBotToken+"/sendMessage" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var message struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&message)
+		mu.Lock()
+		texts = append(texts, message.Text)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok":     true,
+			"result": map[string]interface{}{"message_id": len(texts), "date": 0, "chat": map[string]interface{}{"id": -100123, "type": "channel"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), texts...)
+	}
+}
+
+// budgetPipeline builds a text generator with max_tokens 600 using an
+// openai credential with budgets
+func budgetPipeline(t *testing.T, baseURL string, store *pipelinebase.RunStore, credentialBudgets, pipelineBudgets []nodesbase.Budget, alertURL string) (*pipelinebase.Pipeline, error) {
+	t.Helper()
+
+	openai := map[string]interface{}{"api_key": "local", "base_url": baseURL, "model": "gpt-4o"}
+	if credentialBudgets != nil {
+		openai["budgets"] = credentialBudgets
+	}
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai":   map[string]interface{}{"default": openai},
+		"telegram": map[string]interface{}{"alerts": map[string]interface{}{"token": checkBotToken, "channel_id": "@alerts", "base_url": alertURL}},
+	})
+
+	pipeline, err := pipelinebase.NewPipelineBuilder(credentials).BuildPipelineFromConfig(&pipelinebase.PipelineConfig{
+		Name:    "budget_pipeline",
+		Budgets: pipelineBudgets,
+		Nodes: []nodesbase.NodeDefinition{
+			{ID: "generator", Type: "text_generator", Name: "Generator", Config: map[string]interface{}{"prompt_template": "Motivate me", "max_tokens": 600}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	pipeline.SetRunStore(store)
+	return pipeline, nil
+}
+
+func TestCredentialBudget(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Model: "gpt-4o-2024-08-06", PromptTokens: 1000, CompletionTokens: 500})
+	store := pipelinebase.NewRunStore(t.TempDir())
+	budgets := []nodesbase.Budget{{Tokens: 2000, Period: nodesbase.BudgetPerDay}}
+
+	pipeline, err := budgetPipeline(t, server.URL, store, budgets, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	if _, err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatalf("Expected the first run to fit the budget, got %v", err)
+	}
+
+	// 1500 tokens used and 600 reserved exceed 2000 tokens
+	_, err = pipeline.Execute(context.Background())
+	var budgetErr *nodesbase.BudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Scope != "credential openai/default" || budgetErr.Used != 1500 {
+		t.Fatalf("Expected the second run to be refused by the budget, got %v", err)
+	}
+	if class := services.ClassifyError(err); class != services.ErrorClassBudget {
+		t.Errorf("Expected a budget error, got class %q", class)
+	}
+
+	// With a fallback model, the call goes on with it
+	budgets[0].FallbackModel = "gpt-4o-mini"
+	pipeline, err = budgetPipeline(t, server.URL, store, budgets, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the fallback model to be used, got %v", err)
+	}
+	if model, _ := result.Output("generator", "model_used"); model != "gpt-4o-mini" {
+		t.Errorf("Expected gpt-4o-mini to be used, got %v", model)
+	}
+}
+
+func TestConcurrentCallsReserveBudget(t *testing.T) {
+	// Slow answers make the two generators' calls overlap
+	server := newChatStandIn(t, chatStandIn{Model: "gpt-4o-2024-08-06", PromptTokens: 1000, CompletionTokens: 500, Delay: 50 * time.Millisecond})
+
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai": map[string]interface{}{"default": map[string]interface{}{
+			"api_key": "local", "base_url": server.URL, "model": "gpt-4o",
+			"budgets": []interface{}{map[string]interface{}{"tokens": 1000, "period": "day"}},
+		}},
+	})
+	generator := map[string]interface{}{"prompt_template": "Motivate me", "max_tokens": 600}
+	pipeline, err := pipelinebase.NewPipelineBuilder(credentials).BuildPipeline("concurrent_pipeline", []nodesbase.NodeDefinition{
+		{ID: "topic", Type: "test_echo", Config: map[string]interface{}{"message": "motivation"}},
+		{ID: "first", Type: "text_generator", DependsOn: []string{"topic"}, OnError: nodesbase.OnErrorContinue, Config: generator},
+		{ID: "second", Type: "text_generator", DependsOn: []string{"topic"}, OnError: nodesbase.OnErrorContinue, Config: generator},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	pipeline.SetRunStore(pipelinebase.NewRunStore(t.TempDir()))
+
+	// Each call reserves 600 tokens, so only one fits the 1000 tokens
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if succeeded, failed := result.Succeeded(), result.Failed(); len(succeeded) != 2 || len(failed) != 1 {
+		t.Fatalf("Expected one call within the budget, got %v succeeded and %v failed", succeeded, failed)
+	}
+	if failed := result.Nodes[result.Failed()[0]]; !strings.Contains(failed.Error, "would be exceeded") {
+		t.Errorf("Expected the other call to be refused by the budget, got %q", failed.Error)
+	}
+}
+
+func TestConcurrentRunsShareBudget(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Model: "gpt-4o-2024-08-06", PromptTokens: 1000, CompletionTokens: 500})
+	store := pipelinebase.NewRunStore(t.TempDir())
+	budgets := []nodesbase.Budget{{Tokens: 2000, Period: nodesbase.BudgetPerDay}}
+
+	// The first run holds on after its call, so its usage isn't in the
+	// ledger yet when the second run checks the budget
+	first, err := budgetPipeline(t, server.URL, store, budgets, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	publishing, publish := make(chan struct{}), make(chan struct{})
+	first.AddNode(newFakeNode("publisher", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		close(publishing)
+		<-publish
+		return nil, nil
+	}), "generator")
+	done := make(chan error)
+	go func() {
+		_, err := first.Execute(context.Background())
+		done <- err
+	}()
+	<-publishing
+
+	// 1500 tokens used by the first run and 600 reserved exceed 2000 tokens
+	second, err := budgetPipeline(t, server.URL, store, budgets, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	_, err = second.Execute(context.Background())
+	var budgetErr *nodesbase.BudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Used != 1500 {
+		t.Errorf("Expected the second run to count the usage of the first, got %v", err)
+	}
+
+	close(publish)
+	if err := <-done; err != nil {
+		t.Fatalf("First run failed: %v", err)
+	}
+	// Once recorded, the usage is counted from the ledger alone
+	_, err = second.Execute(context.Background())
+	if !errors.As(err, &budgetErr) || budgetErr.Used != 1500 {
+		t.Errorf("Expected the ledger to hold the usage of the first run, got %v", err)
+	}
+}
+
+func TestFallbackModelReserve(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Model: "gpt-4o-mini", PromptTokens: 100, CompletionTokens: 50, Delay: 50 * time.Millisecond})
+
+	// 600 completion tokens reserve $0.006 with gpt-4o but $0.00036 with
+	// gpt-4o-mini, so both calls fit the second budget once they fall back
+	budgets := []interface{}{
+		map[string]interface{}{"cost": 0.005, "period": "day", "fallback_model": "gpt-4o-mini"},
+		map[string]interface{}{"cost": 0.001, "period": "day"},
+	}
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai": map[string]interface{}{"default": map[string]interface{}{"api_key": "local", "base_url": server.URL, "model": "gpt-4o", "budgets": budgets}},
+	})
+	generator := map[string]interface{}{"prompt_template": "Motivate me", "max_tokens": 600}
+	pipeline, err := pipelinebase.NewPipelineBuilder(credentials).BuildPipeline("fallback_reserve_pipeline", []nodesbase.NodeDefinition{
+		{ID: "topic", Type: "test_echo", Config: map[string]interface{}{"message": "motivation"}},
+		{ID: "first", Type: "text_generator", DependsOn: []string{"topic"}, Config: generator},
+		{ID: "second", Type: "text_generator", DependsOn: []string{"topic"}, Config: generator},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	pipeline.SetRunStore(pipelinebase.NewRunStore(t.TempDir()))
+
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Expected both calls to fall back within the budgets, got %v", err)
+	}
+	for _, id := range []string{"first", "second"} {
+		if model, _ := result.Output(id, "model_used"); model != "gpt-4o-mini" {
+			t.Errorf("Expected %s to use gpt-4o-mini, got %v", id, model)
+		}
+	}
+}
+
+func TestBudgetsWithoutRunStore(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Model: "gpt-4o-2024-08-06", PromptTokens: 1000, CompletionTokens: 500})
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	pipeline, err := budgetPipeline(t, server.URL, nil, []nodesbase.Budget{{Tokens: 2000, Period: nodesbase.BudgetPerDay}}, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	if _, err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if !strings.Contains(logs.String(), "Warning: pipeline budget_pipeline has budgets but no run store") {
+		t.Errorf("Expected a warning that the budgets only count the run, got:\n%s", logs.String())
+	}
+}
+
+func TestPipelineBudgetAlert(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Model: "gpt-4o-2024-08-06", PromptTokens: 1000, CompletionTokens: 500})
+	alerts, sent := newAlertStandIn(t)
+	store := pipelinebase.NewRunStore(t.TempDir())
+
+	// Every call costs $0.0075 and reserves $0.006 for 600 completion tokens
+	budgets := []nodesbase.Budget{{Cost: 0.02, Period: nodesbase.BudgetPerMonth, WarnAt: 0.5, Alert: "alerts"}}
+	for run := 1; run <= 2; run++ {
+		pipeline, err := budgetPipeline(t, server.URL, store, nil, budgets, alerts.URL)
+		if err != nil {
+			t.Fatalf("Failed to build pipeline: %v", err)
+		}
+		if _, err := pipeline.Execute(context.Background()); err != nil {
+			t.Fatalf("Run %d failed: %v", run, err)
+		}
+	}
+
+	// The second run passed the warning threshold; the alert is sent once
+	pipeline, _ := budgetPipeline(t, server.URL, store, nil, budgets, alerts.URL)
+	if _, err := pipeline.Execute(context.Background()); err == nil {
+		t.Fatal("Expected the third run to be refused by the pipeline budget")
+	}
+	if texts := sent(); len(texts) != 1 || !strings.Contains(texts[0], "pipeline budget_pipeline") {
+		t.Errorf("Expected a single alert, got %q", texts)
+	}
+}
+
+func TestInvalidBudgets(t *testing.T) {
+	tests := []struct {
+		name              string
+		credentialBudgets []nodesbase.Budget
+		pipelineBudgets   []nodesbase.Budget
+		problem           string
+	}{
+		{"tokens and cost", []nodesbase.Budget{{Tokens: 10, Cost: 1, Period: nodesbase.BudgetPerDay}}, nil, "either tokens or cost"},
+		{"period", nil, []nodesbase.Budget{{Tokens: 10, Period: "week"}}, "period"},
+		{"warn_at", nil, []nodesbase.Budget{{Cost: 1, Period: nodesbase.BudgetPerDay, WarnAt: 80}}, "warn_at"},
+		{"alert", []nodesbase.Budget{{Cost: 1, Period: nodesbase.BudgetPerDay, Alert: "missing"}}, nil, "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := budgetPipeline(t, "http://localhost", nil, tt.credentialBudgets, tt.pipelineBudgets, "")
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("Expected a problem mentioning %q, got %v", tt.problem, err)
+			}
+		})
+	}
+}
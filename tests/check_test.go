@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"automation-chain/cli"
+	"automation-chain/services"
+)
+
+const checkBotToken = "1234567890:check-bot-token-for-stand-in-server"
+
+// newTelegramStandIn serves the Bot API methods of the health check. The
+// bot has the given role in the channel @check_channel.
+func newTelegramStandIn(t *testing.T, role string, canPost bool) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/bot" + checkBotToken + "/"
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"ok": false, "error_code": 401, "description": "Unauthorized"}`)
+			return
+		}
+
+		var result interface{}
+		switch strings.TrimPrefix(r.URL.Path, prefix) {
+		case "getMe":
+			result = map[string]interface{}{"id": 42, "is_bot": true, "username": "check_bot"}
+		case "getChat":
+			result = map[string]interface{}{"id": -100123, "type": "channel", "title": "Check Channel", "username": "check_channel"}
+		case "getChatMember":
+			result = map[string]interface{}{"status": role, "can_post_messages": canPost, "user": map[string]interface{}{"id": 42}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTelegramCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		canPost bool
+		want    bool
+	}{
+		{"administrator allowed to post", "administrator", true, true},
+		{"administrator not allowed to post", "administrator", false, false},
+		{"member of a channel", "member", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTelegramStandIn(t, tt.role, tt.canPost)
+
+			service := services.NewTelegram()
+			err := service.LoadConfig(map[string]interface{}{"token": checkBotToken, "channel_id": "@check_channel", "base_url": server.URL})
+			if err != nil {
+				t.Fatalf("Failed to configure the service: %v", err)
+			}
+
+			check, err := service.Check(context.Background())
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if check.Bot != "@check_bot" || check.Chat != "Check Channel" || check.Role != tt.role || check.CanPost != tt.want {
+				t.Errorf("Unexpected check %+v", check)
+			}
+		})
+	}
+
+	t.Run("revoked token", func(t *testing.T) {
+		server := newTelegramStandIn(t, "administrator", true)
+
+		service := services.NewTelegram()
+		err := service.LoadConfig(map[string]interface{}{"token": "999999999:revoked-bot-token-for-stand-in-server", "channel_id": "@check_channel", "base_url": server.URL})
+		if err != nil {
+			t.Fatalf("Failed to configure the service: %v", err)
+		}
+
+		if _, err := service.Check(context.Background()); services.ClassifyError(err) != services.ErrorClassAuth {
+			t.Errorf("Expected an auth error, got %v", err)
+		}
+	})
+}
+
+func TestOpenAICheck(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{APIKey: "sk-check-openai-key-for-stand-in", Models: []string{"gpt-4", "gpt-3.5-turbo"}})
+
+	service := services.NewOpenAI()
+	err := service.LoadConfig(map[string]interface{}{"api_key": "sk-check-openai-key-for-stand-in", "model": "gpt-4", "base_url": server.URL + "/v1"})
+	if err != nil {
+		t.Fatalf("Failed to configure the service: %v", err)
+	}
+	check, err := service.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if check.Models != 2 || !check.ModelAvailable {
+		t.Errorf("Unexpected check %+v", check)
+	}
+
+	expired := services.NewOpenAI()
+	err = expired.LoadConfig(map[string]interface{}{"api_key": "sk-expired-openai-key-for-stand-in", "base_url": server.URL + "/v1"})
+	if err != nil {
+		t.Fatalf("Failed to configure the service: %v", err)
+	}
+	if _, err := expired.Check(context.Background()); services.ClassifyError(err) != services.ErrorClassAuth {
+		t.Errorf("Expected an auth error, got %v", err)
+	}
+}
+
+func TestCredsCheckCommand(t *testing.T) {
+	telegram := newTelegramStandIn(t, "administrator", true)
+	openai := newChatStandIn(t, chatStandIn{APIKey: "sk-check-openai-key-for-stand-in", Models: []string{"gpt-3.5-turbo"}})
+
+	path := writeCredentialsFile(t, fmt.Sprintf(`{
+		"openai": {
+			"default": {"api_key": "sk-check-openai-key-for-stand-in", "base_url": %q},
+			"expired": {"api_key": "sk-expired-openai-key-for-stand-in", "base_url": %q}
+		},
+		"telegram": {
+			"check_bot": {"token": %q, "channel_id": "@check_channel", "base_url": %q}
+		}
+	}`, openai.URL+"/v1", openai.URL+"/v1", checkBotToken, telegram.URL))
+
+	if code := cli.Run([]string{"creds", "check", "-credentials", path, "telegram"}); code != 0 {
+		t.Errorf("Expected the telegram credentials to pass, got exit code %d", code)
+	}
+	if code := cli.Run([]string{"creds", "check", "-credentials", path, "openai/default"}); code != 0 {
+		t.Errorf("Expected the default openai credential to pass, got exit code %d", code)
+	}
+	if code := cli.Run([]string{"creds", "check", "-credentials", path}); code != 1 {
+		t.Errorf("Expected the expired openai credential to fail the check, got exit code %d", code)
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"automation-chain/config"
+)
+
+func writeCredentialsFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write credentials: %v", err)
+	}
+	return path
+}
+
+func TestCredentialsLayers(t *testing.T) {
+	path := writeCredentialsFile(t, `{
+		"openai": {
+			"default": {"api_key": "sk-file-default-openai-key", "model": "gpt-4"},
+			"premium": "sk-file-premium-openai-key"
+		},
+		"telegram": {
+			"news_bot": {"token": "file-news-bot-token", "channel_id": "@file_news"}
+		}
+	}`)
+
+	manager := config.NewCredentialsManager()
+	if err := manager.LoadCredentials(path); err != nil {
+		t.Fatalf("Failed to load credentials: %v", err)
+	}
+	manager.LoadEnvironment([]string{
+		"AUTOMATION_CHAIN__OPENAI__DEFAULT__API_KEY=sk-env-default-openai-key",
+		"AUTOMATION_CHAIN__TELEGRAM__ALERTS_BOT__TOKEN=env-alerts-bot-token",
+		"AUTOMATION_CHAIN__TELEGRAM__ALERTS_BOT__CHANNEL_ID=@env_alerts",
+		"HOME=/root",
+	})
+	manager.SetCredentials(map[string]interface{}{
+		"telegram": map[string]interface{}{
+			"news_bot": map[string]interface{}{"channel_id": "@memory_news"},
+		},
+	})
+
+	openai, err := manager.OpenAI("default")
+	if err != nil {
+		t.Fatalf("Failed to look up the default credential: %v", err)
+	}
+	if openai.APIKey != "sk-env-default-openai-key" || openai.Model != "gpt-4" {
+		t.Errorf("Expected the environment to override the api_key only, got %+v", openai)
+	}
+
+	if premium, err := manager.OpenAI("premium"); err != nil || premium.APIKey != "sk-file-premium-openai-key" {
+		t.Errorf("Expected the string shorthand to set the api_key, got %+v (%v)", premium, err)
+	}
+
+	news, err := manager.Telegram("news_bot")
+	if err != nil || news.Token != "file-news-bot-token" || news.ChannelID != "@memory_news" {
+		t.Errorf("Expected the in-memory channel over the file one, got %+v (%v)", news, err)
+	}
+	if alerts, err := manager.Telegram("alerts_bot"); err != nil || alerts.ChannelID != "@env_alerts" {
+		t.Errorf("Expected the credential defined by the environment, got %+v (%v)", alerts, err)
+	}
+
+	if names := manager.Names("telegram"); strings.Join(names, ",") != "alerts_bot,news_bot" {
+		t.Errorf("Unexpected telegram credentials: %v", names)
+	}
+}
+
+func TestCredentialReferences(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secret, []byte("file-secret-bot-token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	t.Setenv("TEST_OPENAI_KEY", "sk-referenced-openai-key")
+
+	manager := config.NewCredentialsManager()
+	manager.SetCredentials(map[string]interface{}{
+		"openai": map[string]interface{}{
+			"default": "${env:TEST_OPENAI_KEY}",
+			"missing": "${env:TEST_UNSET_OPENAI_KEY}",
+		},
+		"telegram": map[string]interface{}{
+			"bot": map[string]interface{}{"token": "${file:" + secret + "}", "channel_id": "@channel"},
+		},
+	})
+
+	if openai, err := manager.OpenAI("default"); err != nil || openai.APIKey != "sk-referenced-openai-key" {
+		t.Errorf("Expected the environment reference to be resolved, got %+v (%v)", openai, err)
+	}
+	if telegram, err := manager.Telegram("bot"); err != nil || telegram.Token != "file-secret-bot-token" {
+		t.Errorf("Expected the file reference to be resolved, got %+v (%v)", telegram, err)
+	}
+
+	_, err := manager.OpenAI("missing")
+	if err == nil || !strings.Contains(err.Error(), "credential openai.missing: api_key: environment variable TEST_UNSET_OPENAI_KEY is not set") {
+		t.Errorf("Expected the unset variable to be reported, got %v", err)
+	}
+}
+
+func TestCredentialLookupErrors(t *testing.T) {
+	manager := config.NewCredentialsManager()
+	manager.SetCredentials(map[string]interface{}{
+		"telegram": map[string]interface{}{
+			"incomplete_bot": map[string]interface{}{"token": "incomplete-bot-token"},
+			"shorthand_bot":  "token-only",
+		},
+	})
+
+	var notFound *config.NotFoundError
+	if _, err := manager.OpenAI("default"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a NotFoundError, got %v", err)
+	}
+	if _, err := manager.Telegram("incomplete_bot"); err == nil || err.Error() != "credential telegram.incomplete_bot: channel_id is required" {
+		t.Errorf("Expected the missing channel to be reported, got %v", err)
+	}
+	if _, err := manager.Telegram("shorthand_bot"); err == nil || !strings.Contains(err.Error(), "telegram credentials must be objects") {
+		t.Errorf("Expected the shorthand to be rejected, got %v", err)
+	}
+}
+
+func TestLoadCredentialsManagerWithoutFile(t *testing.T) {
+	t.Setenv("AUTOMATION_CHAIN__OPENAI__DEFAULT", "sk-environment-only-openai-key")
+
+	manager, err := config.LoadCredentialsManager(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("Expected a missing file to be skipped, got %v", err)
+	}
+	if openai, err := manager.OpenAI("default"); err != nil || openai.APIKey != "sk-environment-only-openai-key" {
+		t.Errorf("Expected the credential from the environment, got %+v (%v)", openai, err)
+	}
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	nodesbase "automation-chain/nodes/base"
+	"automation-chain/nodes/publishers"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/services"
+)
+
+func newTestTelegramPublisher(t *testing.T, parameters map[string]interface{}) *publishers.TelegramPublisherNode {
+	t.Helper()
+
+	parameters["telegram"] = map[string]interface{}{
+		"token":      "123456789:test-telegram-bot-token-for-dry-runs",
+		"channel_id": "@test_channel",
+	}
+	node, err := publishers.NewTelegramPublisherNode(nodesbase.NodeConfig{
+		ID:         "publisher",
+		Type:       "telegram_publisher",
+		Name:       "Publisher",
+		Parameters: parameters,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create publisher: %v", err)
+	}
+	return node
+}
+
+func TestTelegramPublisherDryRun(t *testing.T) {
+	node := newTestTelegramPublisher(t, map[string]interface{}{
+		"message_template": "<b>Daily</b>\n{{ .generated_text }}",
+		"parse_mode":       "HTML",
+	})
+
+	ctx := nodesbase.WithDryRun(context.Background())
+	output, err := node.Execute(ctx, map[string]interface{}{"generated_text": "Keep going &amp; smile"})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+
+	if output["published"] != false || output["dry_run"] != true {
+		t.Errorf("Expected the message not to be published, got %v", output)
+	}
+	if output["message"] != "<b>Daily</b>\nKeep going &amp; smile" {
+		t.Errorf("Unexpected rendered message: %q", output["message"])
+	}
+	if output["parse_mode"] != services.TelegramParseModeHTML {
+		t.Errorf("Expected the canonical parse mode, got %v", output["parse_mode"])
+	}
+}
+
+func TestTelegramPublisherDryRunValidatesMessage(t *testing.T) {
+	node := newTestTelegramPublisher(t, map[string]interface{}{
+		"message_template": "*{{ .generated_text }}",
+		"parse_mode":       "Markdown",
+	})
+
+	ctx := nodesbase.WithDryRun(context.Background())
+	_, err := node.Execute(ctx, map[string]interface{}{"generated_text": "unclosed bold"})
+	if err == nil {
+		t.Fatal("Expected the invalid message to be rejected")
+	}
+	if class := services.ClassifyError(err); class != services.ErrorClassInvalidRequest {
+		t.Errorf("Expected an invalid_request error, got %s", class)
+	}
+}
+
+func TestValidateTelegramMessage(t *testing.T) {
+	cases := []struct {
+		name      string
+		text      string
+		parseMode string
+		valid     bool
+	}{
+		{"plain", "Hello *world", services.TelegramParseModeNone, true},
+		{"empty", "  \n", services.TelegramParseModeNone, false},
+		{"too long", strings.Repeat("a", services.TelegramMaxMessageLength+1), services.TelegramParseModeNone, false},
+		{"markup not counted", "<b>" + strings.Repeat("a", services.TelegramMaxMessageLength) + "</b>", services.TelegramParseModeHTML, true},
+		{"html", `<b>bold</b> <a href="https://example.com">link</a> &lt;3`, services.TelegramParseModeHTML, true},
+		{"html unclosed tag", "<b>bold", services.TelegramParseModeHTML, false},
+		{"html unsupported tag", "<div>text</div>", services.TelegramParseModeHTML, false},
+		{"html unescaped ampersand", "fish & chips", services.TelegramParseModeHTML, false},
+		{"markdown", "*bold* _italic_ `code` [link](https://example.com)", services.TelegramParseModeMarkdown, true},
+		{"markdown unclosed", "*bold", services.TelegramParseModeMarkdown, false},
+		{"markdown v2", `*bold* __underline__ ||spoiler|| Done\!`, services.TelegramParseModeMarkdownV2, true},
+		{"markdown v2 nested", "*bold _italic_*", services.TelegramParseModeMarkdownV2, true},
+		{"markdown v2 link", `[example](https://example.com/a\)b)`, services.TelegramParseModeMarkdownV2, true},
+		{"markdown v2 unescaped", "Done!", services.TelegramParseModeMarkdownV2, false},
+		{"markdown v2 unclosed", "*bold", services.TelegramParseModeMarkdownV2, false},
+	}
+
+	for _, tc := range cases {
+		err := services.ValidateTelegramMessage(tc.text, tc.parseMode)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestDryRunPipelineIsNotCheckpointed(t *testing.T) {
+	dir := t.TempDir()
+	var dryRun bool
+
+	pipeline := pipelinebase.NewPipeline("dry_run_pipeline")
+	pipeline.AddNode(newFakeNode("publisher", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		dryRun = nodesbase.IsDryRun(ctx)
+		return nil, nil
+	}))
+	pipeline.SetRunStore(pipelinebase.NewRunStore(dir))
+	pipeline.SetDryRun(true)
+
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline execution failed: %v", err)
+	}
+
+	if !dryRun || !result.DryRun {
+		t.Error("Expected the dry run to reach the nodes")
+	}
+	if result.RunID != "" {
+		t.Errorf("Expected the dry run not to be recorded, got run %s", result.RunID)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("Expected no run files, found %d", len(entries))
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"automation-chain/config"
+)
+
+const plainCredentials = `{"telegram": {"news_bot": {"token": "encrypted-news-bot-token", "channel_id": "@encrypted_news"}}}`
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	encrypted, err := config.Encrypt([]byte(plainCredentials), []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if !config.IsEncrypted(encrypted) || config.IsEncrypted([]byte(plainCredentials)) {
+		t.Error("Expected only the encrypted file to be detected as encrypted")
+	}
+	if strings.Contains(string(encrypted), "encrypted-news-bot-token") {
+		t.Error("Expected the encrypted file not to contain the token")
+	}
+
+	decrypted, err := config.Decrypt(encrypted, []byte("correct horse"))
+	if err != nil || string(decrypted) != plainCredentials {
+		t.Errorf("Expected the plain credentials back, got %q (%v)", decrypted, err)
+	}
+
+	if _, err := config.Decrypt(encrypted, []byte("battery staple")); !errors.Is(err, config.ErrWrongPassphrase) {
+		t.Errorf("Expected a wrong passphrase error, got %v", err)
+	}
+
+	// Tampered scrypt parameters are refused before deriving the key
+	tampered := strings.Replace(string(encrypted), `"n": 32768`, `"n": 1073741824`, 1)
+	if _, err := config.Decrypt([]byte(tampered), []byte("correct horse")); err == nil || !strings.Contains(err.Error(), "unsupported scrypt parameters") {
+		t.Errorf("Expected tampered scrypt parameters to be rejected, got %v", err)
+	}
+}
+
+func writeEncryptedCredentials(t *testing.T, passphrase string) string {
+	t.Helper()
+
+	encrypted, err := config.Encrypt([]byte(plainCredentials), []byte(passphrase))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "credentials.json"+config.EncryptedSuffix)
+	if err := os.WriteFile(path, encrypted, 0o600); err != nil {
+		t.Fatalf("Failed to write credentials: %v", err)
+	}
+	return path
+}
+
+func TestLoadEncryptedCredentials(t *testing.T) {
+	path := writeEncryptedCredentials(t, "correct horse")
+
+	t.Run("passphrase", func(t *testing.T) {
+		t.Setenv(config.PassphraseEnv, "correct horse")
+
+		// The plain path falls back to its encrypted variant
+		manager, err := config.LoadCredentialsManager(strings.TrimSuffix(path, config.EncryptedSuffix))
+		if err != nil {
+			t.Fatalf("Failed to load credentials: %v", err)
+		}
+		if bot, err := manager.Telegram("news_bot"); err != nil || bot.Token != "encrypted-news-bot-token" {
+			t.Errorf("Expected the decrypted credential, got %+v (%v)", bot, err)
+		}
+	})
+
+	t.Run("key file", func(t *testing.T) {
+		keyFile := filepath.Join(t.TempDir(), "key")
+		if err := os.WriteFile(keyFile, []byte("correct horse\n"), 0o600); err != nil {
+			t.Fatalf("Failed to write the key file: %v", err)
+		}
+		t.Setenv(config.PassphraseEnv, "battery staple")
+		t.Setenv(config.KeyFileEnv, keyFile)
+
+		manager := config.NewCredentialsManager()
+		if err := manager.LoadCredentials(path); err != nil {
+			t.Fatalf("Expected the key file to take precedence, got %v", err)
+		}
+		if _, err := manager.Telegram("news_bot"); err != nil {
+			t.Errorf("Expected the decrypted credential, got %v", err)
+		}
+	})
+
+	t.Run("missing passphrase", func(t *testing.T) {
+		t.Setenv(config.PassphraseEnv, "")
+		t.Setenv(config.KeyFileEnv, "")
+
+		_, err := config.LoadCredentialsManager(path)
+		if err == nil || !strings.Contains(err.Error(), config.PassphraseEnv) {
+			t.Errorf("Expected an error naming %s, got %v", config.PassphraseEnv, err)
+		}
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		t.Setenv(config.PassphraseEnv, "battery staple")
+		t.Setenv(config.KeyFileEnv, "")
+
+		if _, err := config.LoadCredentialsManager(path); !errors.Is(err, config.ErrWrongPassphrase) {
+			t.Errorf("Expected a wrong passphrase error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"automation-chain/config"
+	"automation-chain/services"
+)
+
+func TestOpenAICompatibleServer(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Check: func(r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ollama" {
+			t.Errorf("Expected the configured key, got %q", r.Header.Get("Authorization"))
+		}
+	}})
+
+	service := services.NewOpenAI()
+	// Keys of other servers needn't look like OpenAI keys
+	err := service.LoadConfig(map[string]interface{}{"api_key": "ollama", "model": "llama3", "base_url": server.URL + "/v1"})
+	if err != nil {
+		t.Fatalf("Failed to configure the service: %v", err)
+	}
+
+	completion, err := service.Generate(context.Background(), "Hello", services.GenerateOptions{})
+	if err != nil {
+		t.Fatalf("Expected the local server to answer, got %v", err)
+	}
+	if completion.Text != "served /v1/chat/completions" {
+		t.Errorf("Expected the local server to answer, got %q", completion.Text)
+	}
+
+	if err := services.NewOpenAI().LoadConfig(map[string]interface{}{"api_key": "ollama"}); err == nil {
+		t.Error("Expected a key of another server to be rejected by the OpenAI API")
+	}
+}
+
+func TestAzureOpenAI(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Check: func(r *http.Request) {
+		if r.Header.Get("api-key") != "azure-key" || r.URL.Query().Get("api-version") != "2024-02-01" {
+			t.Errorf("Expected the Azure key and API version, got %v %q", r.Header, r.URL.RawQuery)
+		}
+	}})
+
+	service := services.NewOpenAI()
+	err := service.LoadConfig(map[string]interface{}{
+		"api_key":     "azure-key",
+		"api_type":    "azure",
+		"api_version": "2024-02-01",
+		"deployment":  "motivation",
+		"base_url":    server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to configure the service: %v", err)
+	}
+
+	completion, err := service.Generate(context.Background(), "Hello", services.GenerateOptions{Model: "gpt-4"})
+	if err != nil {
+		t.Fatalf("Expected the deployment to answer, got %v", err)
+	}
+	if completion.Text != "served /openai/deployments/motivation/chat/completions" {
+		t.Errorf("Expected the deployment to answer, got %q", completion.Text)
+	}
+
+	if err := services.NewOpenAI().LoadConfig(map[string]interface{}{"api_key": "azure-key", "api_type": "azure"}); err == nil {
+		t.Error("Expected Azure without base URL to be rejected")
+	}
+}
+
+func TestTelegramThroughProxy(t *testing.T) {
+	upstream := newTelegramStandIn(t, "administrator", true)
+	proxied := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A proxy receives the absolute URL of the request
+		if r.URL.Host != "telegram.invalid" {
+			t.Errorf("Expected a request for telegram.invalid, got %s", r.URL)
+		}
+		proxied++
+		r.URL.Scheme, r.URL.Host = "http", strings.TrimPrefix(upstream.URL, "http://")
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	service := services.NewTelegram()
+	err := service.LoadConfig(map[string]interface{}{
+		"token":      checkBotToken,
+		"channel_id": "@check_channel",
+		"base_url":   "http://telegram.invalid",
+		"proxy":      proxy.URL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to configure the service: %v", err)
+	}
+
+	if _, err := service.Check(context.Background()); err != nil {
+		t.Fatalf("Expected the check to go through the proxy, got %v", err)
+	}
+	if proxied != 3 {
+		t.Errorf("Expected 3 proxied requests, got %d", proxied)
+	}
+}
+
+func TestServiceTimeout(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Delay: 200 * time.Millisecond})
+
+	service := services.NewOpenAI()
+	err := service.LoadConfig(map[string]interface{}{"api_key": "local", "base_url": server.URL, "timeout": "20ms"})
+	if err != nil {
+		t.Fatalf("Failed to configure the service: %v", err)
+	}
+
+	_, err = service.Generate(context.Background(), "Hello", services.GenerateOptions{})
+	if services.ClassifyError(err) != services.ErrorClassTimeout {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
+
+func TestEndpointCredentialSettings(t *testing.T) {
+	manager := config.NewCredentialsManager()
+	manager.SetCredentials(map[string]interface{}{
+		"openai": map[string]interface{}{
+			"azure":     map[string]interface{}{"api_key": "azure-key", "api_type": "azure", "base_url": "https://example.openai.azure.com", "timeout": "45s"},
+			"no_url":    map[string]interface{}{"api_key": "azure-key", "api_type": "azure"},
+			"bad_proxy": map[string]interface{}{"api_key": "sk-endpoint-openai-key", "proxy": "localhost"},
+		},
+		"telegram": map[string]interface{}{
+			"proxied": map[string]interface{}{"token": "endpoint-bot-token", "channel_id": "@c", "proxy": "socks5://proxy:1080", "timeout": 30},
+		},
+	})
+
+	azure, err := manager.OpenAI("azure")
+	if err != nil || azure.APIType != "azure" || azure.Timeout != 45*time.Second {
+		t.Errorf("Unexpected Azure credential %+v (%v)", azure, err)
+	}
+	if _, err := manager.OpenAI("no_url"); err == nil || !strings.Contains(err.Error(), "base_url is required") {
+		t.Errorf("Expected Azure without base_url to be rejected, got %v", err)
+	}
+	if _, err := manager.OpenAI("bad_proxy"); err == nil || !strings.Contains(err.Error(), "proxy must be a URL") {
+		t.Errorf("Expected an invalid proxy to be rejected, got %v", err)
+	}
+
+	telegram, err := manager.Telegram("proxied")
+	if err != nil || telegram.Proxy != "socks5://proxy:1080" || telegram.Timeout != 30*time.Second {
+		t.Errorf("Unexpected Telegram credential %+v (%v)", telegram, err)
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/services"
+)
+
+// fallbackPipeline builds a text generator using the premium openai
+// credential, which is rate limited, with the given fallbacks
+func fallbackPipeline(t *testing.T, generatorConfig map[string]interface{}) (*pipelinebase.Pipeline, error) {
+	t.Helper()
+
+	limited := newChatStandIn(t, chatStandIn{RateLimited: true})
+	backup := newChatStandIn(t, chatStandIn{Model: "gpt-4o-2024-08-06", PromptTokens: 100, CompletionTokens: 50})
+	anthropic := newChatStandIn(t, chatStandIn{APIKey: anthropicKey})
+
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai": map[string]interface{}{
+			"premium": map[string]interface{}{"api_key": "local", "base_url": limited.URL, "model": "gpt-4"},
+			"backup":  map[string]interface{}{"api_key": "local", "base_url": backup.URL, "model": "gpt-4o"},
+		},
+		"anthropic": map[string]interface{}{"default": map[string]interface{}{"api_key": anthropicKey, "base_url": anthropic.URL}},
+	})
+
+	generatorConfig["prompt_template"] = "Write the news"
+	return pipelinebase.NewPipelineBuilder(credentials).BuildPipeline("fallback_pipeline", []nodesbase.NodeDefinition{
+		{ID: "generator", Type: "text_generator", Credentials: "premium", Config: generatorConfig},
+	})
+}
+
+func TestTextGeneratorFallbacks(t *testing.T) {
+	fallbacks := []interface{}{
+		map[string]interface{}{"provider": "anthropic", "credential": "default", "model": "overloaded"},
+		map[string]interface{}{"credential": "backup", "model": "gpt-4o-mini"},
+	}
+	pipeline, err := fallbackPipeline(t, map[string]interface{}{"fallbacks": fallbacks})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+
+	// The premium credential is rate limited and Anthropic overloaded
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the last fallback to generate the text, got %v", err)
+	}
+	output := result.Outputs["generator"]
+	if output["credential_used"] != "openai/backup" || output["model_used"] != "gpt-4o-mini" || output["provider_used"] != services.ProviderOpenAI {
+		t.Errorf("Expected openai/backup and gpt-4o-mini to be recorded, got %v", output)
+	}
+	if usage, _ := nodesbase.UsageOf(output); usage.Credential != "openai/backup" || usage.TotalTokens != 150 {
+		t.Errorf("Expected the usage of the fallback, got %+v", usage)
+	}
+
+	// Server errors don't move on to the next fallback when fallback_on
+	// only lists rate limits
+	pipeline, err = fallbackPipeline(t, map[string]interface{}{"fallbacks": fallbacks, "fallback_on": []interface{}{"rate_limit"}})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	_, err = pipeline.Execute(context.Background())
+	if services.ClassifyError(err) != services.ErrorClassServer {
+		t.Errorf("Expected the overloaded fallback to fail the node, got %v", err)
+	}
+}
+
+func TestInvalidFallbacks(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		problem string
+	}{
+		{"unknown credential", map[string]interface{}{"fallbacks": []interface{}{map[string]interface{}{"credential": "missing"}}}, `"missing" not found`},
+		{"unknown field", map[string]interface{}{"fallbacks": []interface{}{map[string]interface{}{"model": "gpt-4o", "temperature": 0.5}}}, `unknown field "temperature"`},
+		{"empty", map[string]interface{}{"fallbacks": []interface{}{map[string]interface{}{}}}, "credential or a model"},
+		{"provider without credential", map[string]interface{}{"fallbacks": []interface{}{map[string]interface{}{"provider": "anthropic", "model": "claude-3-5-haiku-latest"}}}, "credential is required"},
+		{"error class", map[string]interface{}{"fallback_on": []interface{}{"weather"}}, "unknown error class in fallback_on: weather"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fallbackPipeline(t, tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("Expected a problem mentioning %q, got %v", tt.problem, err)
+			}
+		})
+	}
+}
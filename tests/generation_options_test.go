@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+// generationRequest is the part of a chat completion request the option
+// tests look at
+type generationRequest struct {
+	Model            string   `json:"model"`
+	MaxTokens        int      `json:"max_tokens"`
+	Temperature      *float32 `json:"temperature"`
+	TopP             *float32 `json:"top_p"`
+	FrequencyPenalty float32  `json:"frequency_penalty"`
+	PresencePenalty  float32  `json:"presence_penalty"`
+}
+
+// captureGeneration runs a text generator with the given config against a
+// stand-in server and returns the request it received
+func captureGeneration(t *testing.T, generator map[string]interface{}) generationRequest {
+	t.Helper()
+
+	var got generationRequest
+	server := newChatStandIn(t, chatStandIn{Check: func(r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}})
+
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai": map[string]interface{}{"default": map[string]interface{}{"api_key": "local", "base_url": server.URL, "model": "gpt-4o"}},
+	})
+	generator["prompt_template"] = "Motivate me"
+	pipeline, err := pipelinebase.NewPipelineBuilder(credentials).BuildPipeline("options_pipeline", []nodesbase.NodeDefinition{
+		{ID: "generator", Type: "text_generator", Config: generator},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	if _, err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	return got
+}
+
+func TestTextGeneratorRequestOptions(t *testing.T) {
+	got := captureGeneration(t, map[string]interface{}{
+		"model":             "gpt-4o-mini",
+		"max_tokens":        123,
+		"temperature":       0,
+		"top_p":             0.5,
+		"frequency_penalty": 0.25,
+		"presence_penalty":  -0.5,
+	})
+
+	if got.Model != "gpt-4o-mini" || got.MaxTokens != 123 || got.FrequencyPenalty != 0.25 || got.PresencePenalty != -0.5 {
+		t.Errorf("Expected the node options in the request, got %+v", got)
+	}
+	if got.TopP == nil || *got.TopP != 0.5 {
+		t.Errorf("Expected top_p 0.5, got %v", got.TopP)
+	}
+	// The client omits zero values, but an explicit zero is still sent
+	if got.Temperature == nil || *got.Temperature != 0 {
+		t.Errorf("Expected temperature 0 to be sent, got %v", got.Temperature)
+	}
+
+	if got = captureGeneration(t, map[string]interface{}{"top_p": 0}); got.TopP == nil || *got.TopP != 0 {
+		t.Errorf("Expected top_p 0 to be sent, got %v", got.TopP)
+	}
+
+	// Unset options are left to the API defaults
+	got = captureGeneration(t, map[string]interface{}{})
+	if got.Model != "gpt-4o" || got.MaxTokens != 0 || got.Temperature != nil || got.TopP != nil {
+		t.Errorf("Expected the credential's model and no sampling options, got %+v", got)
+	}
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/services"
+)
+
+// chatRequest is the part of a request the message tests look at
+type chatRequest struct {
+	System   string             `json:"system"`
+	Messages []services.Message `json:"messages"`
+}
+
+// messagesPipeline builds a chat reader, echoing a history, and a text
+// generator with the given config and credential section
+func messagesPipeline(t *testing.T, credentials map[string]interface{}, generator map[string]interface{}) (*pipelinebase.Pipeline, error) {
+	t.Helper()
+
+	manager := config.NewCredentialsManager()
+	manager.SetCredentials(credentials)
+	history := []interface{}{
+		map[string]interface{}{"role": "user", "content": "Yesterday's quote?"},
+		map[string]interface{}{"role": "assistant", "content": "La constancia vence lo que la dicha no alcanza."},
+		map[string]interface{}{"role": "user", "content": "{{ .not_a_template }}"},
+	}
+
+	return pipelinebase.NewPipelineBuilder(manager).BuildPipeline("messages_pipeline", []nodesbase.NodeDefinition{
+		{ID: "chat_reader", Type: "test_echo", Config: map[string]interface{}{"message": history}},
+		{ID: "writer", Type: "text_generator", Credentials: "default", DependsOn: []string{"chat_reader"}, Config: generator},
+	})
+}
+
+func TestTextGeneratorMessages(t *testing.T) {
+	var got chatRequest
+	server := newChatStandIn(t, chatStandIn{Check: func(r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}})
+
+	pipeline, err := messagesPipeline(t, map[string]interface{}{
+		"openai": map[string]interface{}{"default": map[string]interface{}{"api_key": "local", "base_url": server.URL}},
+	}, map[string]interface{}{
+		"system_prompt":   "Write in Spanish, no emojis, no hashtags, after {{ len .message }} messages.",
+		"prompt_template": "Another quote, in Spanish",
+		"examples": []interface{}{
+			map[string]interface{}{"role": "user", "content": "A quote about {{ index .nodes.chat_reader.message 0 \"content\" }}"},
+			map[string]interface{}{"role": "assistant", "content": "Quien habla dos lenguas vale por dos."},
+		},
+		"history":       "nodes.chat_reader.message",
+		"history_limit": 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	if _, err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	want := []services.Message{
+		{Role: "system", Content: "Write in Spanish, no emojis, no hashtags, after 3 messages."},
+		{Role: "user", Content: "A quote about Yesterday's quote?"},
+		{Role: "assistant", Content: "Quien habla dos lenguas vale por dos."},
+		// The last two messages of the history, sent as is
+		{Role: "assistant", Content: "La constancia vence lo que la dicha no alcanza."},
+		{Role: "user", Content: "{{ .not_a_template }}"},
+		{Role: "user", Content: "Another quote, in Spanish"},
+	}
+	if !reflect.DeepEqual(got.Messages, want) {
+		t.Errorf("Expected messages %+v, got %+v", want, got.Messages)
+	}
+}
+
+func TestAnthropicSystemPrompt(t *testing.T) {
+	var got chatRequest
+	server := newChatStandIn(t, chatStandIn{APIKey: anthropicKey, Check: func(r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}})
+
+	pipeline, err := messagesPipeline(t, map[string]interface{}{
+		"anthropic": map[string]interface{}{"default": map[string]interface{}{"api_key": anthropicKey, "base_url": server.URL}},
+	}, map[string]interface{}{
+		"provider":        "anthropic",
+		"system_prompt":   "No emojis.",
+		"prompt_template": "Another quote",
+		"history":         "nodes.chat_reader.message",
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	// The last message of the history and the prompt are merged, since
+	// Anthropic only accepts alternating roles
+	want := []services.Message{
+		{Role: "user", Content: "Yesterday's quote?"},
+		{Role: "assistant", Content: "La constancia vence lo que la dicha no alcanza."},
+		{Role: "user", Content: "{{ .not_a_template }}\n\nAnother quote"},
+	}
+	if got.System != "No emojis." || !reflect.DeepEqual(got.Messages, want) {
+		t.Errorf("Expected the system prompt apart from messages %+v, got %+v", want, got)
+	}
+	if text, _ := result.Output("writer", "generated_text"); !strings.HasSuffix(text.(string), "\n\nAnother quote") {
+		t.Errorf("Expected the prompt to end the last message, got %v", text)
+	}
+
+	// A conversation starting with the assistant can't be merged into shape
+	pipeline, err = messagesPipeline(t, map[string]interface{}{
+		"anthropic": map[string]interface{}{"default": map[string]interface{}{"api_key": anthropicKey, "base_url": server.URL}},
+	}, map[string]interface{}{
+		"provider":        "anthropic",
+		"prompt_template": "Another quote",
+		"examples":        []interface{}{map[string]interface{}{"role": "assistant", "content": "Quien habla dos lenguas vale por dos."}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	if _, err := pipeline.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "Anthropic conversations must start with a user message, not an assistant one") {
+		t.Errorf("Expected a conversation starting with the assistant to be rejected, got %v", err)
+	}
+}
+
+func TestTextGeneratorInvalidMessages(t *testing.T) {
+	credentials := map[string]interface{}{"openai": map[string]interface{}{"default": "sk-messages-test-openai-key"}}
+
+	_, err := messagesPipeline(t, credentials, map[string]interface{}{
+		"prompt_template": "Hello",
+		"examples": []interface{}{
+			map[string]interface{}{"role": "system", "content": "Be brief"},
+			map[string]interface{}{"role": "user", "content": "{{ .topic"},
+			"Hello",
+		},
+	})
+	if err == nil {
+		t.Fatal("Expected invalid examples to be rejected")
+	}
+	for _, problem := range []string{"examples[0]: role must be user or assistant", "invalid template examples[1]", "examples[2]: expected an object"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q in %v", problem, err)
+		}
+	}
+
+	pipeline, err := messagesPipeline(t, credentials, map[string]interface{}{
+		"prompt_template": "Hello",
+		"history":         "nodes.chat_reader",
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	if _, err := pipeline.Execute(context.Background()); err == nil || !strings.Contains(err.Error(), "history must be a list of messages") {
+		t.Errorf("Expected a history that isn't a list to fail, got %v", err)
+	}
+
+	// The root of a history path must be an upstream node
+	_, err = messagesPipeline(t, credentials, map[string]interface{}{
+		"prompt_template": "Hello",
+		"history":         "nodes.chatreader.message",
+	})
+	if err == nil || !strings.Contains(err.Error(), `node writer: history "nodes.chatreader.message" names unknown node chatreader; did you mean "chat_reader"?`) {
+		t.Errorf("Expected a misspelled history node to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	"automation-chain/nodes/docs"
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+func TestBuilderChecksParameters(t *testing.T) {
+	builder := pipelinebase.NewPipelineBuilder(config.NewCredentialsManager())
+
+	_, err := builder.BuildPipeline("parameters_pipeline", []nodesbase.NodeDefinition{
+		{ID: "writer", Type: "text_generator", Name: "Writer", Config: map[string]interface{}{
+			"temprature": 0.7,
+			"max_tokens": 12.5,
+			"top_p":      3,
+		}},
+		{ID: "poster", Type: "telegram_publisher", Name: "Poster", DependsOn: []string{"writer"}, Config: map[string]interface{}{
+			"parse_mode": "markdown",
+		}},
+	})
+	var validationErr *pipelinebase.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+
+	expected := []string{
+		"node writer: required parameter prompt_template is missing",
+		"node writer: max_tokens must be an integer, got 12.5",
+		"node writer: unknown parameter \"temprature\"; did you mean \"temperature\"?",
+		"node writer: top_p must be at most 1, got 3",
+		"node poster: parse_mode must be one of Markdown, MarkdownV2, HTML, got \"markdown\"; did you mean \"Markdown\"?",
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected problem %q in:\n%v", problem, err)
+		}
+	}
+}
+
+func TestBuiltInNodeTypesDescribeThemselves(t *testing.T) {
+	for _, nodeType := range builtInNodeTypes() {
+		if nodeType.Category == "" || nodeType.Description == "" {
+			t.Errorf("%s: expected a category and a description", nodeType.Type)
+		}
+		if nodeType.Parameters == nil || nodeType.Outputs == nil {
+			t.Errorf("%s: expected its parameters and outputs to be described", nodeType.Type)
+		}
+		for _, param := range nodeType.Parameters {
+			if param.Default != nil {
+				if err := param.Check(param.Default); err != nil {
+					t.Errorf("%s: invalid default: %v", nodeType.Type, err)
+				}
+			}
+		}
+	}
+}
+
+// TestNodeDocumentationIsUpToDate fails when a node type changes without
+// regenerating its reference
+func TestNodeDocumentationIsUpToDate(t *testing.T) {
+	data, err := os.ReadFile("../docs/NODES_DOCUMENTATION.md")
+	if err != nil {
+		t.Fatalf("Failed to read the node documentation: %v", err)
+	}
+
+	updated, err := docs.Update(string(data), builtInNodeTypes())
+	if err != nil {
+		t.Fatalf("Failed to update the node documentation: %v", err)
+	}
+	if updated != string(data) {
+		t.Error("docs/NODES_DOCUMENTATION.md is out of date, run: go run main.go nodes docs -update docs/NODES_DOCUMENTATION.md")
+	}
+}
+
+// builtInNodeTypes returns the registered node types, without the ones
+// registered by these tests
+func builtInNodeTypes() []nodesbase.NodeType {
+	var types []nodesbase.NodeType
+	for _, nodeType := range nodesbase.NodeTypes() {
+		if !strings.HasPrefix(nodeType.Type, "test_") {
+			types = append(types, nodeType)
+		}
+	}
+	return types
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+func failing(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("boom")
+}
+
+func succeeding(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{"ok": true}, nil
+}
+
+func TestOnErrorContinueSkipsDependents(t *testing.T) {
+	pipeline := pipelinebase.NewPipeline("continue_pipeline")
+	pipeline.AddNode(newFakeNode("generator", succeeding))
+	pipeline.AddNodeWithOptions(newFakeNode("telegram_news", failing), pipelinebase.NodeOptions{
+		DependsOn: []string{"generator"},
+		OnError:   nodesbase.OnErrorContinue,
+	})
+	pipeline.AddNode(newFakeNode("summary", succeeding), "telegram_news")
+	pipeline.AddNode(newFakeNode("telegram_personal", succeeding), "generator")
+
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the run to continue after the failure, got: %v", err)
+	}
+
+	if got := result.Succeeded(); !reflect.DeepEqual(got, []string{"generator", "telegram_personal"}) {
+		t.Errorf("Unexpected succeeded nodes: %v", got)
+	}
+	if got := result.Failed(); !reflect.DeepEqual(got, []string{"telegram_news"}) {
+		t.Errorf("Unexpected failed nodes: %v", got)
+	}
+	if got := result.Skipped(); !reflect.DeepEqual(got, []string{"summary"}) {
+		t.Errorf("Unexpected skipped nodes: %v", got)
+	}
+	if result.Nodes["telegram_news"].Error == "" {
+		t.Error("Expected the failure to be recorded")
+	}
+}
+
+func TestOnErrorRouteRunsErrorHandler(t *testing.T) {
+	var handlerInput map[string]interface{}
+
+	pipeline := pipelinebase.NewPipeline("route_pipeline")
+	pipeline.AddNode(newFakeNode("generator", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"generated_text": "hello"}, nil
+	}))
+	pipeline.AddNodeWithOptions(newFakeNode("publisher", failing), pipelinebase.NodeOptions{
+		DependsOn:    []string{"generator"},
+		OnError:      nodesbase.OnErrorRoute,
+		ErrorHandler: "notify",
+	})
+	pipeline.AddNode(newFakeNode("notify", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		handlerInput = input
+		return map[string]interface{}{"notified": true}, nil
+	}))
+
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the failure to be handled, got: %v", err)
+	}
+
+	if got := result.Nodes["publisher"]; got.Status != pipelinebase.NodeFailed || got.HandledBy != "notify" {
+		t.Errorf("Expected publisher to fail and be handled by notify, got %+v", got)
+	}
+	if got := result.Nodes["notify"].Status; got != pipelinebase.NodeSucceeded {
+		t.Errorf("Expected the error handler to succeed, got %s", got)
+	}
+
+	failure, ok := handlerInput[nodesbase.ErrorKey].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected the error handler to receive the failure, got %v", handlerInput)
+	}
+	if failure["node_id"] != "publisher" || failure["class"] != "unknown" {
+		t.Errorf("Unexpected failure details: %v", failure)
+	}
+	if handlerInput["generated_text"] != "hello" {
+		t.Errorf("Expected the error handler to receive the input of the failed node, got %v", handlerInput)
+	}
+}
+
+func TestTelegramPublisherHandlesErrors(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{RateLimited: true})
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai":   map[string]interface{}{"default": map[string]interface{}{"api_key": "local", "base_url": server.URL}},
+		"telegram": map[string]interface{}{"alerts": map[string]interface{}{"token": checkBotToken, "channel_id": "@alerts"}},
+	})
+
+	// The alert only reads the error, not the generated text
+	pipeline, err := pipelinebase.NewPipelineBuilder(credentials).BuildPipeline("alert_pipeline", []nodesbase.NodeDefinition{
+		{ID: "gen", Type: "text_generator", Name: "Generate", OnError: nodesbase.OnErrorRoute, ErrorHandler: "alert", Config: map[string]interface{}{"prompt_template": "Write the news"}},
+		{ID: "alert", Type: "telegram_publisher", Name: "Alert", Credentials: "alerts", Config: map[string]interface{}{"message_template": "Generation failed: {{ .error.message }}"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected a telegram_publisher to handle the errors of a text_generator, got: %v", err)
+	}
+
+	result, err := pipeline.Execute(nodesbase.WithDryRun(context.Background()))
+	if err != nil {
+		t.Fatalf("Expected the failure to be handled, got: %v", err)
+	}
+	if message, _ := result.Output("alert", "message"); !strings.Contains(fmt.Sprint(message), "Generation failed: ") || !strings.Contains(fmt.Sprint(message), "Rate limit") {
+		t.Errorf("Expected the alert to describe the failure, got %q", message)
+	}
+}
+
+func TestTelegramPublisherHandlerChecksItsTemplate(t *testing.T) {
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai":   map[string]interface{}{"default": map[string]interface{}{"api_key": "local", "base_url": "http://127.0.0.1:1"}},
+		"telegram": map[string]interface{}{"alerts": map[string]interface{}{"token": checkBotToken, "channel_id": "@alerts"}},
+	})
+
+	// The default message template reads generated_text, which nothing
+	// upstream of the failed node produces
+	_, err := pipelinebase.NewPipelineBuilder(credentials).BuildPipeline("alert_pipeline", []nodesbase.NodeDefinition{
+		{ID: "gen", Type: "text_generator", Name: "Generate", OnError: nodesbase.OnErrorRoute, ErrorHandler: "alert", Config: map[string]interface{}{"prompt_template": "Write the news"}},
+		{ID: "alert", Type: "telegram_publisher", Name: "Alert", Credentials: "alerts"},
+	})
+	expected := `node alert: telegram_publisher expects "generated_text" when handling errors of gen but has no upstream node producing it`
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected the error to contain %q, got: %v", expected, err)
+	}
+}
+
+func TestErrorHandlerDoesNotRunWithoutFailure(t *testing.T) {
+	ran := false
+	pipeline := pipelinebase.NewPipeline("route_pipeline")
+	pipeline.AddNodeWithOptions(newFakeNode("publisher", succeeding), pipelinebase.NodeOptions{
+		OnError:      nodesbase.OnErrorRoute,
+		ErrorHandler: "notify",
+	})
+	pipeline.AddNode(newFakeNode("notify", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		ran = true
+		return nil, nil
+	}))
+
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline execution failed: %v", err)
+	}
+	if ran {
+		t.Error("Error handler should only run when a failure is routed to it")
+	}
+	if got := result.Nodes["notify"].Status; got != pipelinebase.NodeSkipped {
+		t.Errorf("Expected the unused error handler to be skipped, got %s", got)
+	}
+}
+
+func TestOnErrorFailRecordsStatuses(t *testing.T) {
+	pipeline := pipelinebase.NewPipeline("fail_pipeline")
+	pipeline.AddNode(newFakeNode("first", failing))
+	pipeline.AddNode(newFakeNode("second", succeeding), "first")
+
+	result, err := pipeline.Execute(context.Background())
+	if err == nil {
+		t.Fatal("Expected pipeline to fail")
+	}
+	if got := result.Failed(); !reflect.DeepEqual(got, []string{"first"}) {
+		t.Errorf("Unexpected failed nodes: %v", got)
+	}
+	if got := result.Skipped(); !reflect.DeepEqual(got, []string{"second"}) {
+		t.Errorf("Unexpected skipped nodes: %v", got)
+	}
+}
+
+func TestInvalidOnErrorConfiguration(t *testing.T) {
+	cases := map[string]pipelinebase.NodeOptions{
+		"unknown mode":          {OnError: "retry"},
+		"missing handler":       {OnError: nodesbase.OnErrorRoute},
+		"unknown handler":       {OnError: nodesbase.OnErrorRoute, ErrorHandler: "missing"},
+		"handler without route": {ErrorHandler: "notify"},
+	}
+
+	for name, options := range cases {
+		pipeline := pipelinebase.NewPipeline("invalid_pipeline")
+		pipeline.AddNodeWithOptions(newFakeNode("publisher", succeeding), options)
+		pipeline.AddNode(newFakeNode("notify", succeeding))
+
+		if err := pipeline.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestErrorHandlerHandlesOneNode(t *testing.T) {
+	pipeline := pipelinebase.NewPipeline("fan_in_pipeline")
+	for _, id := range []string{"news", "personal"} {
+		pipeline.AddNodeWithOptions(newFakeNode(id, failing), pipelinebase.NodeOptions{
+			OnError:      nodesbase.OnErrorRoute,
+			ErrorHandler: "notify",
+		})
+	}
+	pipeline.AddNode(newFakeNode("notify", succeeding))
+
+	err := pipeline.Validate()
+	if err == nil || !strings.Contains(err.Error(), "node personal: error handler notify already handles the errors of news") {
+		t.Errorf("Expected the second route to notify to be rejected, got: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	nodesbase "automation-chain/nodes/base"
+	"automation-chain/nodes/publishers"
+)
+
+type testParams struct {
+	Prompt    *nodesbase.Template    `param:"prompt" required:"true" desc:"Prompt"`
+	Style     string                 `param:"style" enum:"formal,casual" default:"casual"`
+	Count     int                    `param:"count" min:"1" max:"10" default:"3"`
+	Ratio     *float64               `param:"ratio" min:"0" max:"1"`
+	Enabled   bool                   `param:"enabled"`
+	Timeout   nodesbase.Duration     `param:"timeout" default:"30s"`
+	Tags      []string               `param:"tags"`
+	Options   map[string]interface{} `param:"options"`
+	Unexposed string
+}
+
+func TestDecodeParams(t *testing.T) {
+	var params testParams
+	err := nodesbase.DecodeParams(nodesbase.NodeConfig{ID: "decoder", Parameters: map[string]interface{}{
+		"prompt":   "Write about {{ .topic }}",
+		"count":    float64(5),
+		"ratio":    0.5,
+		"enabled":  true,
+		"timeout":  float64(2),
+		"tags":     []interface{}{"a", "b"},
+		"options":  map[string]interface{}{"key": "value"},
+		"telegram": map[string]interface{}{"token": "ignored"},
+	}}, &params)
+	if err != nil {
+		t.Fatalf("Failed to decode parameters: %v", err)
+	}
+
+	if prompt, _ := params.Prompt.Render(map[string]interface{}{"topic": "go"}); prompt != "Write about go" {
+		t.Errorf("Unexpected prompt: %q", prompt)
+	}
+	if params.Style != "casual" || params.Count != 5 || params.Ratio == nil || *params.Ratio != 0.5 || !params.Enabled {
+		t.Errorf("Unexpected parameters: %+v", params)
+	}
+	if time.Duration(params.Timeout) != 2*time.Second {
+		t.Errorf("Expected a timeout of 2s, got %v", params.Timeout)
+	}
+	if len(params.Tags) != 2 || params.Options["key"] != "value" {
+		t.Errorf("Unexpected collections: %v %v", params.Tags, params.Options)
+	}
+
+	var defaults testParams
+	if err := nodesbase.DecodeParams(nodesbase.NodeConfig{ID: "decoder", Parameters: map[string]interface{}{"prompt": "hi"}}, &defaults); err != nil {
+		t.Fatalf("Failed to decode parameters: %v", err)
+	}
+	if defaults.Count != 3 || defaults.Ratio != nil || time.Duration(defaults.Timeout) != 30*time.Second {
+		t.Errorf("Expected the defaults, got %+v", defaults)
+	}
+}
+
+func TestDecodeParamsReportsEveryProblem(t *testing.T) {
+	var params testParams
+	err := nodesbase.DecodeParams(nodesbase.NodeConfig{ID: "decoder", Parameters: map[string]interface{}{
+		"style":   "Formal",
+		"count":   float64(11),
+		"ratio":   "half",
+		"timeout": "soon",
+		"tags":    []interface{}{"a", 1},
+	}}, &params)
+
+	var paramsErr *nodesbase.ParamsError
+	if !errors.As(err, &paramsErr) {
+		t.Fatalf("Expected a ParamsError, got %v", err)
+	}
+	if paramsErr.NodeID != "decoder" || !strings.HasPrefix(err.Error(), "node decoder: 6 invalid parameters") {
+		t.Errorf("Expected the node ID and the problem count, got %v", err)
+	}
+	for _, problem := range []string{
+		"required parameter prompt is missing",
+		`style must be one of formal, casual, got "Formal"; did you mean "formal"?`,
+		"count must be at most 10, got 11",
+		`ratio must be a number, got "half"`,
+		`timeout must be a duration such as "30s", got "soon"`,
+		"tags must be an array of strings, got 1 at index 1",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected problem %q in:\n%v", problem, err)
+		}
+	}
+}
+
+func TestParamSpecsOf(t *testing.T) {
+	specs := nodesbase.ParamSpecsOf(testParams{})
+	if len(specs) != 8 {
+		t.Fatalf("Expected 8 parameters, got %d", len(specs))
+	}
+
+	prompt, count, timeout := specs[0], specs[2], specs[5]
+	if prompt.Type != nodesbase.ParamTemplate || !prompt.Required || prompt.Description != "Prompt" {
+		t.Errorf("Unexpected prompt spec: %+v", prompt)
+	}
+	if count.Type != nodesbase.ParamInteger || count.Default != 3 || *count.Min != 1 || *count.Max != 10 {
+		t.Errorf("Unexpected count spec: %+v", count)
+	}
+	if timeout.Type != nodesbase.ParamDuration || timeout.Default != "30s" {
+		t.Errorf("Unexpected timeout spec: %+v", timeout)
+	}
+}
+
+func TestNodeConstructorsReportInvalidParameters(t *testing.T) {
+	_, err := publishers.NewTelegramPublisherNode(nodesbase.NodeConfig{
+		ID:   "publisher",
+		Type: "telegram_publisher",
+		Parameters: map[string]interface{}{
+			"message_template": 42,
+			"parse_mode":       true,
+		},
+	})
+	if err == nil {
+		t.Fatal("Expected the invalid parameters to be rejected")
+	}
+	for _, problem := range []string{
+		"node publisher: 2 invalid parameters",
+		"message_template must be a string, got 42",
+		"parse_mode must be a string, got true",
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected problem %q in:\n%v", problem, err)
+		}
+	}
+}
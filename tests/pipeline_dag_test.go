@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+// fakeNode is a node whose behaviour is driven by a function
+type fakeNode struct {
+	config  nodesbase.NodeConfig
+	execute func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error)
+}
+
+func newFakeNode(id string, execute func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error)) *fakeNode {
+	return &fakeNode{
+		config:  nodesbase.NodeConfig{ID: id, Type: "fake", Name: id},
+		execute: execute,
+	}
+}
+
+func (n *fakeNode) Execute(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+	return n.execute(ctx, input)
+}
+
+func (n *fakeNode) Name() string                 { return n.config.Name }
+func (n *fakeNode) Config() nodesbase.NodeConfig { return n.config }
+func (n *fakeNode) Validate() error              { return nil }
+
+func TestPipelineRunsIndependentBranchesConcurrently(t *testing.T) {
+	pipeline := pipelinebase.NewPipeline("dag_pipeline")
+
+	pipeline.AddNode(newFakeNode("generator", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"generated_text": "hello"}, nil
+	}))
+
+	// Every publisher waits until all of them have started, which only
+	// happens if they run concurrently
+	var started sync.WaitGroup
+	started.Add(3)
+	var mu sync.Mutex
+	received := make(map[string]string)
+
+	for _, id := range []string{"publisher_a", "publisher_b", "publisher_c"} {
+		id := id
+		pipeline.AddNode(newFakeNode(id, func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+			started.Done()
+			done := make(chan struct{})
+			go func() {
+				started.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				return nil, fmt.Errorf("publishers did not run concurrently")
+			}
+
+			mu.Lock()
+			received[id], _ = input["generated_text"].(string)
+			mu.Unlock()
+			return map[string]interface{}{"published": true}, nil
+		}), "generator")
+	}
+
+	if _, err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatalf("Pipeline execution failed: %v", err)
+	}
+
+	for _, id := range []string{"publisher_a", "publisher_b", "publisher_c"} {
+		if received[id] != "hello" {
+			t.Errorf("Expected %s to receive generated_text, got %q", id, received[id])
+		}
+	}
+}
+
+func TestPipelineDetectsCycles(t *testing.T) {
+	noop := func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return nil, nil
+	}
+
+	pipeline := pipelinebase.NewPipeline("cyclic_pipeline")
+	pipeline.AddNode(newFakeNode("a", noop), "c")
+	pipeline.AddNode(newFakeNode("b", noop), "a")
+	pipeline.AddNode(newFakeNode("c", noop), "b")
+
+	err := pipeline.Validate()
+	if err == nil {
+		t.Fatal("Expected cycle to be detected")
+	}
+	if !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected cycle error, got: %v", err)
+	}
+}
+
+func TestPipelineStopsOnFirstError(t *testing.T) {
+	ran := false
+	pipeline := pipelinebase.NewPipeline("failing_pipeline")
+	pipeline.AddNode(newFakeNode("first", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return nil, fmt.Errorf("boom")
+	}))
+	pipeline.AddNode(newFakeNode("second", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		ran = true
+		return nil, nil
+	}), "first")
+
+	if _, err := pipeline.Execute(context.Background()); err == nil {
+		t.Fatal("Expected pipeline to fail")
+	}
+	if ran {
+		t.Error("Dependent node should not run after its dependency failed")
+	}
+}
+
+func TestPipelineNamespacesNodeOutputs(t *testing.T) {
+	publisher := func(channel string) func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"channel_id": channel, "published": true}, nil
+		}
+	}
+
+	var summaryInput map[string]interface{}
+	pipeline := pipelinebase.NewPipeline("namespaced_pipeline")
+	pipeline.AddNode(newFakeNode("generator", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"generated_text": "hello"}, nil
+	}))
+	pipeline.AddNode(newFakeNode("telegram_news", publisher("@news")), "generator")
+	pipeline.AddNode(newFakeNode("telegram_personal", publisher("@personal")), "generator")
+	pipeline.AddNode(newFakeNode("summary", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		summaryInput = input
+		return nil, nil
+	}), "telegram_news", "telegram_personal")
+
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline execution failed: %v", err)
+	}
+
+	if channel, _ := result.Output("telegram_news", "channel_id"); channel != "@news" {
+		t.Errorf("Expected telegram_news channel_id @news, got %v", channel)
+	}
+	if channel, _ := result.Output("telegram_personal", "channel_id"); channel != "@personal" {
+		t.Errorf("Expected telegram_personal channel_id @personal, got %v", channel)
+	}
+
+	// Upstream outputs are merged, those of direct dependencies last
+	if summaryInput["generated_text"] != "hello" || summaryInput["channel_id"] != "@personal" {
+		t.Errorf("Expected summary to receive the merged upstream outputs, got %v", summaryInput)
+	}
+
+	generator, ok := nodesbase.UpstreamOutput(summaryInput, "generator")
+	if !ok || generator["generated_text"] != "hello" {
+		t.Errorf("Expected summary to see the generator output, got %v", generator)
+	}
+	news, ok := nodesbase.UpstreamOutput(summaryInput, "telegram_news")
+	if !ok || news["channel_id"] != "@news" {
+		t.Errorf("Expected summary to see the telegram_news output, got %v", news)
+	}
+}
+
+func TestSequentialPublishersReceiveGeneratedText(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{})
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai":   map[string]interface{}{"default": map[string]interface{}{"api_key": "local", "base_url": server.URL}},
+		"telegram": map[string]interface{}{"news": map[string]interface{}{"token": checkBotToken, "channel_id": "@news"}},
+	})
+
+	// Without depends_on the nodes run one after another, and the second
+	// publisher still reads the text generated two nodes before
+	pipeline, err := pipelinebase.NewPipelineBuilder(credentials).BuildPipeline("sequential_pipeline", []nodesbase.NodeDefinition{
+		{ID: "gen", Type: "text_generator", Config: map[string]interface{}{"prompt_template": "Write the news"}},
+		{ID: "first", Type: "telegram_publisher", Credentials: "news"},
+		{ID: "second", Type: "telegram_publisher", Credentials: "news"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	result, err := pipeline.Execute(nodesbase.WithDryRun(context.Background()))
+	if err != nil {
+		t.Fatalf("Pipeline execution failed: %v", err)
+	}
+	for _, id := range []string{"first", "second"} {
+		if message, _ := result.Output(id, "message"); !strings.Contains(fmt.Sprint(message), "served /chat/completions") {
+			t.Errorf("Expected %s to publish the generated text, got %q", id, message)
+		}
+	}
+}
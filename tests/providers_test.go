@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"automation-chain/cli"
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/services"
+)
+
+const anthropicKey = "sk-ant-REDACTED"
+
+func TestAnthropicProvider(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{APIKey: anthropicKey, Models: []string{services.AnthropicModel}})
+
+	provider, err := services.NewLLMProvider(services.ProviderAnthropic)
+	if err != nil {
+		t.Fatalf("Failed to create the provider: %v", err)
+	}
+	if err := provider.LoadConfig(map[string]interface{}{"api_key": anthropicKey, "base_url": server.URL}); err != nil {
+		t.Fatalf("Failed to configure the provider: %v", err)
+	}
+
+	completion, err := provider.Generate(context.Background(), "Hello", services.GenerateOptions{})
+	if err != nil {
+		t.Fatalf("Expected the default model to answer, got %v", err)
+	}
+	if completion.Text != services.AnthropicModel+": Hello" {
+		t.Errorf("Expected the default model to answer, got %q", completion.Text)
+	}
+
+	_, err = provider.Generate(context.Background(), "Hello", services.GenerateOptions{Model: "overloaded"})
+	if services.ClassifyError(err) != services.ErrorClassServer {
+		t.Errorf("Expected an overloaded API to be a server error, got %v", err)
+	}
+
+	check, err := provider.Check(context.Background())
+	if err != nil || check.Models != 1 || !check.ModelAvailable {
+		t.Errorf("Unexpected check %+v (%v)", check, err)
+	}
+
+	revoked := services.NewAnthropic()
+	if err := revoked.LoadConfig(map[string]interface{}{"api_key": "sk-ant-revoked", "base_url": server.URL}); err != nil {
+		t.Fatalf("Failed to configure the provider: %v", err)
+	}
+	if _, err := revoked.Check(context.Background()); services.ClassifyError(err) != services.ErrorClassAuth {
+		t.Errorf("Expected an auth error, got %v", err)
+	}
+
+	if _, err := services.NewLLMProvider("mistral"); err == nil {
+		t.Error("Expected an unknown provider to be rejected")
+	}
+}
+
+func TestTextGeneratorProviders(t *testing.T) {
+	anthropic := newChatStandIn(t, chatStandIn{APIKey: anthropicKey})
+	ollama := newChatStandIn(t, chatStandIn{})
+
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"anthropic": map[string]interface{}{
+			"default": map[string]interface{}{"api_key": anthropicKey, "base_url": anthropic.URL, "model": "claude-3-5-sonnet-latest"},
+		},
+		"ollama": map[string]interface{}{
+			"default": map[string]interface{}{"base_url": ollama.URL + "/v1"},
+		},
+		"openai": map[string]interface{}{
+			// An Ollama server kept among the OpenAI credentials
+			"local": map[string]interface{}{"provider": "ollama", "base_url": ollama.URL + "/v1", "model": "mistral"},
+		},
+	})
+
+	builder := pipelinebase.NewPipelineBuilder(credentials)
+	pipeline, err := builder.BuildPipeline("providers_pipeline", []nodesbase.NodeDefinition{
+		{ID: "claude", Type: "text_generator", Credentials: "default", Config: map[string]interface{}{"provider": "anthropic", "prompt_template": "Hello"}},
+		{ID: "llama", Type: "text_generator", Credentials: "default", Config: map[string]interface{}{"provider": "ollama", "prompt_template": "Hello"}},
+		{ID: "mistral", Type: "text_generator", Credentials: "local", Config: map[string]interface{}{"prompt_template": "Hello"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	tests := []struct {
+		node     string
+		provider string
+		model    string
+		text     string
+	}{
+		{"claude", "anthropic", "claude-3-5-sonnet-latest", "claude-3-5-sonnet-latest: Hello"},
+		{"llama", "ollama", services.OllamaModel, "served /v1/chat/completions"},
+		{"mistral", "ollama", "mistral", "served /v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		provider, _ := result.Output(tt.node, "provider_used")
+		model, _ := result.Output(tt.node, "model_used")
+		text, _ := result.Output(tt.node, "generated_text")
+		if provider != tt.provider || model != tt.model || text != tt.text {
+			t.Errorf("%s: expected %s/%s to answer %q, got %v/%v %q", tt.node, tt.provider, tt.model, tt.text, provider, model, text)
+		}
+	}
+
+	// The provider selects the credentials section
+	_, err = builder.BuildPipeline("missing_pipeline", []nodesbase.NodeDefinition{
+		{ID: "claude", Type: "text_generator", Credentials: "local", Config: map[string]interface{}{"provider": "anthropic", "prompt_template": "Hello"}},
+	})
+	if err == nil {
+		t.Error("Expected a credential missing from the anthropic section to be rejected")
+	}
+}
+
+func TestCredsCheckProviders(t *testing.T) {
+	anthropic := newChatStandIn(t, chatStandIn{APIKey: anthropicKey, Models: []string{"claude-3-5-haiku-latest"}})
+	ollama := newChatStandIn(t, chatStandIn{APIKey: "ollama", Models: []string{"llama3"}})
+
+	path := writeCredentialsFile(t, fmt.Sprintf(`{
+		"anthropic": {"default": {"api_key": %q, "base_url": %q}},
+		"ollama": {"default": {"base_url": %q}}
+	}`, anthropicKey, anthropic.URL, ollama.URL+"/v1"))
+
+	if code := cli.Run([]string{"creds", "check", "-credentials", path}); code != 0 {
+		t.Errorf("Expected the anthropic and ollama credentials to pass, got exit code %d", code)
+	}
+}
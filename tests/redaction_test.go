@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"automation-chain/config"
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+func TestRedactorMasksSecrets(t *testing.T) {
+	redactor := config.NewRedactor()
+	redactor.Add("short", "redaction-test-secret", "redaction-test-secret-longer")
+
+	masked := redactor.Redact("a redaction-test-secret-longer and a redaction-test-secret, but short")
+	if masked != "a [REDACTED] and a [REDACTED], but short" {
+		t.Errorf("Expected the secrets to be masked whole and short values kept, got %q", masked)
+	}
+
+	// Well-known secrets are masked without being registered
+	url := "https://api.telegram.org/bot1234567890:AAbbCCddEEffGGhhIIjjKKllMMnnOOppQQr/getUpdates"
+	if masked := redactor.Redact(url); masked != "https://api.telegram.org/bot[REDACTED]/getUpdates" {
+		t.Errorf("Expected the bot token to be masked, got %q", masked)
+	}
+	if masked := redactor.Redact("invalid key sk-abcdefghijklmnopqrstuvwxyz012345"); masked != "invalid key [REDACTED]" {
+		t.Errorf("Expected the API key to be masked, got %q", masked)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintln(redactor.Writer(&out), "token redaction-test-secret")
+	if out.String() != "token [REDACTED]\n" {
+		t.Errorf("Expected the writer to mask the secret, got %q", out.String())
+	}
+
+	cause := errors.New("request with redaction-test-secret failed")
+	err := redactor.Error(fmt.Errorf("publish: %w", cause))
+	if err.Error() != "publish: request with [REDACTED] failed" || !errors.Is(err, cause) {
+		t.Errorf("Expected a masked error wrapping the cause, got %v", err)
+	}
+}
+
+func TestCredentialSecretsAreRedacted(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secretFile, []byte("redaction-file-bot-token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write the secret: %v", err)
+	}
+
+	manager := config.NewCredentialsManager()
+	manager.SetCredentials(map[string]interface{}{
+		"openai": map[string]interface{}{
+			"redaction": "redaction-memory-openai-key",
+		},
+		"telegram": map[string]interface{}{
+			"redaction_bot": map[string]interface{}{
+				"token":      "${file:" + secretFile + "}",
+				"channel_id": "@redaction_channel",
+			},
+		},
+	})
+	manager.LoadEnvironment([]string{"AUTOMATION_CHAIN__TELEGRAM__REDACTION_ENV__TOKEN=redaction-env-bot-token"})
+	if _, err := manager.Telegram("redaction_bot"); err != nil {
+		t.Fatalf("Failed to look up the credential: %v", err)
+	}
+
+	text := config.Redact("redaction-memory-openai-key redaction-env-bot-token redaction-file-bot-token @redaction_channel")
+	if text != "[REDACTED] [REDACTED] [REDACTED] @redaction_channel" {
+		t.Errorf("Expected the secrets, but not the channel, to be masked, got %q", text)
+	}
+}
+
+func TestRunRecordsAreRedacted(t *testing.T) {
+	config.Secrets().Add("redaction-run-secret")
+	dir := t.TempDir()
+
+	var logs bytes.Buffer
+	log.SetOutput(config.Secrets().Writer(&logs))
+	defer log.SetOutput(os.Stderr)
+
+	pipeline := pipelinebase.NewPipeline("redaction_pipeline")
+	pipeline.AddNode(newFakeNode("publisher", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return nil, fmt.Errorf("POST https://example.com/redaction-run-secret/send failed")
+	}))
+	pipeline.SetRunStore(pipelinebase.NewRunStore(dir))
+
+	result, err := pipeline.Execute(context.Background())
+	if err == nil {
+		t.Fatal("Expected the run to fail")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, result.RunID+".json"))
+	if err != nil {
+		t.Fatalf("Failed to read the run: %v", err)
+	}
+	if strings.Contains(string(data), "redaction-run-secret") || !strings.Contains(string(data), config.Redacted) {
+		t.Errorf("Expected the secret to be masked in the run record, got %s", data)
+	}
+	if strings.Contains(logs.String(), "redaction-run-secret") {
+		t.Errorf("Expected the secret to be masked in the log, got %s", logs.String())
+	}
+}
+
+func TestRunRecordsRedactEscapedSecrets(t *testing.T) {
+	// JSON escapes these characters, so the stored form differs from the secret
+	secret := `redaction<run>&"escaped"\secret`
+	config.Secrets().Add(secret)
+	dir := t.TempDir()
+
+	pipeline := pipelinebase.NewPipeline("redaction_pipeline")
+	pipeline.AddNode(newFakeNode("writer", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"generated_text": "token " + secret, "tags": []string{secret}}, nil
+	}))
+	pipeline.AddNodeWithOptions(newFakeNode("publisher", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return nil, fmt.Errorf("token %s was rejected", secret)
+	}), pipelinebase.NodeOptions{DependsOn: []string{"writer"}})
+	pipeline.SetRunStore(pipelinebase.NewRunStore(dir))
+
+	result, err := pipeline.Execute(context.Background())
+	if err == nil {
+		t.Fatal("Expected the run to fail")
+	}
+	if strings.Contains(result.Nodes["publisher"].Error, secret) {
+		t.Errorf("Expected the secret to be masked in the node error, got %q", result.Nodes["publisher"].Error)
+	}
+
+	run, err := pipelinebase.NewRunStore(dir).Load(result.RunID)
+	if err != nil {
+		t.Fatalf("Failed to load the run: %v", err)
+	}
+	stored := fmt.Sprint(run.Error, run.Nodes["publisher"].Error, run.Outputs)
+	if strings.Contains(stored, secret) || strings.Count(stored, config.Redacted) != 4 {
+		t.Errorf("Expected the secret to be masked in the run record, got %s", stored)
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+func init() {
+	nodesbase.RegisterNodeType(nodesbase.NodeType{
+		Type:        "test_echo",
+		Description: "Echoes its message parameter",
+		Factory: func(config nodesbase.NodeConfig) (nodesbase.Node, error) {
+			return newFakeNode(config.ID, func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+				return map[string]interface{}{"message": config.Parameters["message"]}, nil
+			}), nil
+		},
+	})
+}
+
+func TestBuiltInNodeTypesAreRegistered(t *testing.T) {
+	for _, name := range []string{"text_generator", "telegram_publisher"} {
+		if _, exists := nodesbase.LookupNodeType(name); !exists {
+			t.Errorf("Expected node type %s to be registered", name)
+		}
+	}
+}
+
+func TestBuilderUsesRegisteredNodeTypes(t *testing.T) {
+	builder := pipelinebase.NewPipelineBuilder(config.NewCredentialsManager())
+
+	pipeline, err := builder.BuildPipeline("registry_pipeline", []nodesbase.NodeDefinition{
+		{ID: "echo", Type: "test_echo", Name: "Echo", Config: map[string]interface{}{"message": "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+
+	result, err := pipeline.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline execution failed: %v", err)
+	}
+	if message, _ := result.Output("echo", "message"); message != "hi" {
+		t.Errorf("Expected message hi, got %v", message)
+	}
+
+	if _, err := builder.BuildPipeline("unknown_pipeline", []nodesbase.NodeDefinition{
+		{ID: "unknown", Type: "does_not_exist", Name: "Unknown"},
+	}); err == nil {
+		t.Error("Expected an error for an unknown node type")
+	}
+}
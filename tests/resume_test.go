@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+func TestResumeReusesSucceededOutputs(t *testing.T) {
+	store := pipelinebase.NewRunStore(t.TempDir())
+
+	generated := 0
+	publishFails := true
+	var published interface{}
+
+	newPipeline := func() *pipelinebase.Pipeline {
+		pipeline := pipelinebase.NewPipeline("resume_pipeline")
+		pipeline.AddNode(newFakeNode("generator", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+			generated++
+			return map[string]interface{}{"generated_text": "hello"}, nil
+		}))
+		pipeline.AddNode(newFakeNode("publisher", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+			if publishFails {
+				return nil, fmt.Errorf("telegram unavailable")
+			}
+			published = input["generated_text"]
+			return map[string]interface{}{"published": true}, nil
+		}), "generator")
+		pipeline.SetRunStore(store)
+		return pipeline
+	}
+
+	result, err := newPipeline().Execute(context.Background())
+	if err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+	if result.RunID == "" {
+		t.Fatal("Expected the run to be recorded")
+	}
+
+	run, err := store.Load(result.RunID)
+	if err != nil {
+		t.Fatalf("Failed to load run: %v", err)
+	}
+	if run.Status != pipelinebase.RunFailed || run.Nodes["generator"].Status != pipelinebase.NodeSucceeded {
+		t.Fatalf("Unexpected checkpointed run: %+v", run)
+	}
+	if run.Outputs["generator"]["generated_text"] != "hello" {
+		t.Fatalf("Expected the generator output to be checkpointed, got %v", run.Outputs)
+	}
+
+	publishFails = false
+	result, err = newPipeline().Resume(context.Background(), run)
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	if generated != 1 {
+		t.Errorf("Expected the generator to run once, ran %d times", generated)
+	}
+	if published != "hello" {
+		t.Errorf("Expected the publisher to receive the stored text, got %v", published)
+	}
+	if !result.Nodes["generator"].Reused {
+		t.Error("Expected the generator output to be marked as reused")
+	}
+
+	run, err = store.Load(result.RunID)
+	if err != nil {
+		t.Fatalf("Failed to load run: %v", err)
+	}
+	if run.Status != pipelinebase.RunSucceeded || run.Resumes != 1 {
+		t.Errorf("Expected the resumed run to succeed, got status %s after %d resumes", run.Status, run.Resumes)
+	}
+
+	if _, err := newPipeline().Resume(context.Background(), run); err == nil {
+		t.Error("Expected a completed run not to be resumable")
+	}
+}
+
+func TestRunStoreRejectsInvalidIDs(t *testing.T) {
+	store := pipelinebase.NewRunStore(t.TempDir())
+	if _, err := store.Load("../credentials"); err == nil {
+		t.Error("Expected an invalid run ID to be rejected")
+	}
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	nodesbase "automation-chain/nodes/base"
+	"automation-chain/nodes/publishers"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/services"
+)
+
+// flakyNode returns a node failing with the given class for the first failures attempts
+func flakyNode(id string, failures int, class services.ErrorClass, attempts *int) *fakeNode {
+	return newFakeNode(id, func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		*attempts++
+		if *attempts <= failures {
+			return nil, &services.ServiceError{Service: "test", Class: class, Err: errors.New("transient failure")}
+		}
+		return map[string]interface{}{"ok": true}, nil
+	})
+}
+
+func fastRetry(maxAttempts int, retryOn ...string) *nodesbase.RetryPolicy {
+	return &nodesbase.RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: nodesbase.Duration(time.Millisecond),
+		MaxBackoff:     nodesbase.Duration(5 * time.Millisecond),
+		Jitter:         0.5,
+		RetryOn:        retryOn,
+	}
+}
+
+func TestRetryRecoversFromTransientErrors(t *testing.T) {
+	attempts := 0
+	pipeline := pipelinebase.NewPipeline("retry_pipeline")
+	pipeline.AddNodeWithOptions(flakyNode("flaky", 2, services.ErrorClassRateLimit, &attempts), pipelinebase.NodeOptions{
+		Retry: fastRetry(3),
+	})
+
+	if _, err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatalf("Pipeline execution failed: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryDoesNotRetryAuthErrors(t *testing.T) {
+	attempts := 0
+	pipeline := pipelinebase.NewPipeline("auth_pipeline")
+	pipeline.AddNodeWithOptions(flakyNode("flaky", 5, services.ErrorClassAuth, &attempts), pipelinebase.NodeOptions{
+		Retry: fastRetry(3),
+	})
+
+	_, err := pipeline.Execute(context.Background())
+	if err == nil {
+		t.Fatal("Expected pipeline to fail")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt for an auth error, got %d", attempts)
+	}
+	if services.ClassifyError(err) != services.ErrorClassAuth {
+		t.Errorf("Expected the error class to survive wrapping, got %s", services.ClassifyError(err))
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	attempts := 0
+	pipeline := pipelinebase.NewPipeline("exhausted_pipeline")
+	pipeline.AddNodeWithOptions(flakyNode("flaky", 5, services.ErrorClassServer, &attempts), pipelinebase.NodeOptions{
+		Retry: fastRetry(2, "server"),
+	})
+
+	if _, err := pipeline.Execute(context.Background()); err == nil {
+		t.Fatal("Expected pipeline to fail")
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	var policy nodesbase.RetryPolicy
+	if err := json.Unmarshal([]byte(`{"max_attempts": 3, "initial_backoff": "500ms", "max_backoff": 10, "retry_on": ["rate_limit"]}`), &policy); err != nil {
+		t.Fatalf("Failed to parse retry policy: %v", err)
+	}
+	if time.Duration(policy.InitialBackoff) != 500*time.Millisecond || time.Duration(policy.MaxBackoff) != 10*time.Second {
+		t.Errorf("Unexpected backoffs: %s, %s", policy.InitialBackoff, policy.MaxBackoff)
+	}
+
+	policy.RetryOn = []string{"rate_limt"}
+	pipeline := pipelinebase.NewPipeline("invalid_retry_pipeline")
+	pipeline.AddNodeWithOptions(newFakeNode("node", nil), pipelinebase.NodeOptions{Retry: &policy})
+	if err := pipeline.Validate(); err == nil {
+		t.Error("Expected an error for an unknown error class")
+	}
+}
+
+func TestTimedOutTelegramAttemptIsNotDelivered(t *testing.T) {
+	var mu sync.Mutex
+	requests, delivered := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		first := requests == 1
+		mu.Unlock()
+
+		// The first attempt outlasts the node timeout, and is only
+		// delivered if the client is still waiting for it. The server only
+		// notices a cancelled request once its body is read.
+		io.Copy(io.Discard, r.Body)
+		if first {
+			select {
+			case <-time.After(200 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		mu.Lock()
+		delivered++
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ok":     true,
+			"result": map[string]interface{}{"message_id": 1, "date": 0, "chat": map[string]interface{}{"id": -100123, "type": "channel"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	publisher, err := publishers.NewTelegramPublisherNode(nodesbase.NodeConfig{ID: "publish", Type: "telegram_publisher", Name: "publish", Parameters: map[string]interface{}{
+		"telegram": map[string]interface{}{"token": checkBotToken, "channel_id": "@news", "base_url": server.URL},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create publisher: %v", err)
+	}
+	pipeline := pipelinebase.NewPipeline("timeout_retry_pipeline")
+	pipeline.AddNode(newFakeNode("writer", func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"generated_text": "Good morning"}, nil
+	}))
+	pipeline.AddNodeWithOptions(publisher, pipelinebase.NodeOptions{
+		DependsOn: []string{"writer"},
+		Timeout:   50 * time.Millisecond,
+		Retry:     fastRetry(2),
+	})
+	if _, err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatalf("Expected the retry to publish the message, got %v", err)
+	}
+
+	// Leave the abandoned attempt the time to deliver, if it wasn't cancelled
+	time.Sleep(300 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 || delivered != 1 {
+		t.Errorf("Expected the message to be delivered once in 2 attempts, got %d deliveries in %d", delivered, requests)
+	}
+}
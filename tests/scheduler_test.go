@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/pipelines/scheduler"
+)
+
+func TestParseSchedule(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+	from := time.Date(2025, 7, 25, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     string
+		timezone string
+		expected time.Time
+	}{
+		{"five fields", "0 9 * * *", "", time.Date(2025, 7, 26, 9, 0, 0, 0, time.UTC)},
+		{"with seconds", "30 0 9 * * *", "", time.Date(2025, 7, 26, 9, 0, 30, 0, time.UTC)},
+		{"descriptor", "@hourly", "", time.Date(2025, 7, 25, 10, 0, 0, 0, time.UTC)},
+		{"timezone", "0 12 * * *", "Europe/Madrid", time.Date(2025, 7, 25, 12, 0, 0, 0, madrid)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := scheduler.ParseSchedule(tt.spec, tt.timezone)
+			if err != nil {
+				t.Fatalf("Failed to parse schedule: %v", err)
+			}
+
+			next := schedule.Next(from)
+			if !next.Equal(tt.expected) {
+				t.Errorf("Expected next run at %s, got %s", tt.expected, next)
+			}
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	if _, err := scheduler.ParseSchedule("0 25 * * *", ""); err == nil {
+		t.Error("Expected an error for an invalid hour")
+	}
+	if _, err := scheduler.ParseSchedule("0 9 * * *", "Mars/Olympus"); err == nil {
+		t.Error("Expected an error for an unknown timezone")
+	}
+	if _, err := scheduler.ParseSchedule("", ""); err == nil {
+		t.Error("Expected an error for an empty schedule")
+	}
+}
+
+func TestSchedulerRunsAndShutsDown(t *testing.T) {
+	started := make(chan struct{}, 10)
+	cancelled := make(chan error, 10)
+	s := scheduler.New(func(ctx context.Context, config *pipelinebase.PipelineConfig) error {
+		started <- struct{}{}
+		// A run outlasting the grace period is cancelled
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ctx.Err()
+	})
+	if err := s.Add(&pipelinebase.PipelineConfig{Name: "every_second", Schedule: "@every 1s"}); err != nil {
+		t.Fatalf("Failed to schedule pipeline: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		s.Run(ctx, 100*time.Millisecond)
+		close(stopped)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		cancel()
+		t.Fatal("Expected the pipeline to run within its interval")
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the scheduler to stop after its grace period")
+	}
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the running pipeline to be cancelled, got %v", err)
+		}
+	default:
+		t.Error("Expected the running pipeline to be cancelled before the scheduler stopped")
+	}
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"automation-chain/cli"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/pipelines/schema"
+)
+
+func TestSchemaReportsLineAndColumn(t *testing.T) {
+	data := []byte(`{
+  "name": "typo_pipeline",
+  "timeout": "30 seconds",
+  "nodes": [
+    {
+      "id": "text_generator",
+      "type": "text_generator",
+      "name": "Generator",
+      "on_error": "route",
+      "config": {
+        "prompt_template": "Write something",
+        "temprature": 0.7,
+        "max_tokens": 0
+      }
+    },
+    {
+      "id": "publisher",
+      "type": "telegram_publsher",
+      "name": "Publisher"
+    }
+  ]
+}`)
+
+	errs := schema.Validate("typo.json", data, schema.Pipeline())
+
+	expected := []string{
+		`typo.json:3:14: timeout: invalid duration "30 seconds"`,
+		`typo.json:5:5: nodes[0]: missing required property "error_handler"`,
+		`typo.json:12:9: nodes[0].config: unknown property "temprature"; did you mean "temperature"?`,
+		`typo.json:13:23: nodes[0].config.max_tokens: must be at least 1, got 0`,
+		`typo.json:18:15: nodes[1].type: invalid value "telegram_publsher"`,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d problems, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(errs[i].Error(), prefix) {
+			t.Errorf("Expected problem %d to start with %q, got %q", i, prefix, errs[i].Error())
+		}
+	}
+	if !strings.Contains(errs[4].Error(), `did you mean "telegram_publisher"?`) {
+		t.Errorf("Expected a suggestion for the node type, got %q", errs[4].Error())
+	}
+}
+
+func TestSchemaReportsSyntaxErrors(t *testing.T) {
+	data := []byte("{\n  \"name\": \"broken\",\n  \"nodes\": [\n    {\"id\": \"a\",}\n  ]\n}")
+
+	errs := schema.Validate("broken.json", data, schema.Pipeline())
+	if len(errs) != 1 {
+		t.Fatalf("Expected a single syntax error, got %v", errs)
+	}
+	if errs[0].Line != 4 || !strings.Contains(errs[0].Message, "invalid JSON") {
+		t.Errorf("Expected a syntax error on line 4, got %q", errs[0].Error())
+	}
+}
+
+func TestBundledFilesMatchSchemas(t *testing.T) {
+	files, err := filepath.Glob("../config/pipelines/*.json")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Expected bundled pipelines, got %v (%v)", files, err)
+	}
+
+	pipeline := schema.Pipeline()
+	for _, file := range files {
+		errs, err := schema.ValidateFile(file, pipeline)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, problem := range errs {
+			t.Errorf("Unexpected problem: %v", problem)
+		}
+	}
+
+	errs, err := schema.ValidateFile("../config/credentials_example.json", schema.Credentials())
+	if err != nil {
+		t.Fatalf("Failed to read the example credentials: %v", err)
+	}
+	for _, problem := range errs {
+		t.Errorf("Unexpected problem: %v", problem)
+	}
+}
+
+// TestSchemaCoversConfigFields guards against configuration fields added
+// without updating the schema
+func TestSchemaCoversConfigFields(t *testing.T) {
+	pipeline := schema.Pipeline()
+	node := pipeline.Properties["nodes"].Items
+
+	for _, check := range []struct {
+		value      interface{}
+		properties map[string]*schema.Schema
+	}{
+		{pipelinebase.PipelineConfig{}, pipeline.Properties},
+		{nodesbase.NodeDefinition{}, node.Properties},
+		{nodesbase.RetryPolicy{}, node.Properties["retry"].Properties},
+	} {
+		valueType := reflect.TypeOf(check.value)
+		for i := 0; i < valueType.NumField(); i++ {
+			name := strings.Split(valueType.Field(i).Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			if _, exists := check.properties[name]; !exists {
+				t.Errorf("%s.%s (%q) is missing from the schema", valueType.Name(), valueType.Field(i).Name, name)
+			}
+		}
+	}
+}
+
+func TestSchemaExport(t *testing.T) {
+	data, err := json.Marshal(schema.Pipeline())
+	if err != nil {
+		t.Fatalf("Failed to marshal the schema: %v", err)
+	}
+
+	var exported map[string]interface{}
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("Failed to unmarshal the schema: %v", err)
+	}
+	if exported["$schema"] != schema.Draft {
+		t.Errorf("Expected $schema %q, got %v", schema.Draft, exported["$schema"])
+	}
+	if exported["additionalProperties"] != false {
+		t.Errorf("Expected unknown properties to be forbidden, got %v", exported["additionalProperties"])
+	}
+}
+
+func TestSubcommandHelp(t *testing.T) {
+	for _, args := range [][]string{
+		{"validate", "-h"},
+		{"schema", "-h"},
+		{"run", "-h"},
+		{"resume", "-h"},
+		{"serve", "-h"},
+		{"creds", "-h"},
+		{"creds", "check", "-h"},
+	} {
+		if code := cli.Run(args); code != 0 {
+			t.Errorf("Expected %q to print its usage and exit 0, got %d", strings.Join(args, " "), code)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chatStandIn describes a stand-in for the API of a language model
+// provider. It serves the OpenAI chat completions and the Anthropic Messages
+// API, and the models lists of both.
+type chatStandIn struct {
+	// APIKey, when set, is required as a Bearer token by the OpenAI API and
+	// as x-api-key by the Anthropic one
+	APIKey string
+	// Models are listed by the models endpoints
+	Models []string
+	// Model is reported by chat completions; Anthropic answers with the
+	// requested one
+	Model string
+	// Text answers chat completions; "served <path>" when empty. Anthropic
+	// answers with the model and the last message.
+	Text string
+	// PromptTokens and CompletionTokens are reported as the usage of a call
+	PromptTokens     int
+	CompletionTokens int
+	// RateLimited fails every chat completion with a rate limit error
+	RateLimited bool
+	// Delay is waited before answering
+	Delay time.Duration
+	// Check is called with every request, whose body it may read
+	Check func(r *http.Request)
+}
+
+// newChatStandIn starts a stand-in serving the given API
+func newChatStandIn(t *testing.T, standIn chatStandIn) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if standIn.Check != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			standIn.Check(r)
+		}
+		time.Sleep(standIn.Delay)
+
+		anthropic := r.Header.Get("anthropic-version") != ""
+		if standIn.APIKey != "" {
+			key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if anthropic {
+				key = r.Header.Get("x-api-key")
+			}
+			if key != standIn.APIKey {
+				w.WriteHeader(http.StatusUnauthorized)
+				if anthropic {
+					fmt.Fprint(w, `{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`)
+				} else {
+					fmt.Fprint(w, `{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error", "code": "invalid_api_key"}}`)
+				}
+				return
+			}
+		}
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/chat/completions"):
+			standIn.serveCompletion(w, r)
+		case r.URL.Path == "/v1/messages" && anthropic:
+			serveAnthropicMessage(w, body)
+		case strings.HasSuffix(r.URL.Path, "/models"):
+			data := make([]map[string]interface{}, len(standIn.Models))
+			for i, model := range standIn.Models {
+				data[i] = map[string]interface{}{"id": model, "object": "model", "type": "model"}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "data": data, "has_more": false})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// serveCompletion answers a chat completion request of the OpenAI API
+func (s chatStandIn) serveCompletion(w http.ResponseWriter, r *http.Request) {
+	if s.RateLimited {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error": {"message": "Rate limit reached for gpt-4", "type": "requests", "code": "rate_limit_exceeded"}}`)
+		return
+	}
+
+	text := s.Text
+	if text == "" {
+		text = "served " + r.URL.Path
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"object":  "chat.completion",
+		"model":   s.Model,
+		"choices": []map[string]interface{}{{"index": 0, "message": map[string]interface{}{"role": "assistant", "content": text}}},
+		"usage": map[string]interface{}{
+			"prompt_tokens":     s.PromptTokens,
+			"completion_tokens": s.CompletionTokens,
+			"total_tokens":      s.PromptTokens + s.CompletionTokens,
+		},
+	})
+}
+
+// serveAnthropicMessage answers a request of the Anthropic Messages API with
+// the model and the last message. The "overloaded" model is always
+// overloaded.
+func serveAnthropicMessage(w http.ResponseWriter, body []byte) {
+	var request struct {
+		Model     string `json:"model"`
+		MaxTokens int    `json:"max_tokens"`
+		Messages  []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	invalid := func(message string) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"type": "error", "error": {"type": "invalid_request_error", "message": %q}}`, message)
+	}
+	if err := json.Unmarshal(body, &request); err != nil || request.MaxTokens == 0 || len(request.Messages) == 0 {
+		invalid("max_tokens and messages are required")
+		return
+	}
+	for i, message := range request.Messages {
+		if want := []string{"user", "assistant"}[i%2]; message.Role != want {
+			invalid(fmt.Sprintf("messages: roles must alternate between user and assistant, starting with user; messages.%d is %s", i, message.Role))
+			return
+		}
+	}
+	if request.Model == "overloaded" {
+		w.Header().Set("retry-after", "3")
+		w.WriteHeader(529)
+		fmt.Fprint(w, `{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`)
+		return
+	}
+
+	last := request.Messages[len(request.Messages)-1]
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":        "message",
+		"role":        "assistant",
+		"model":       request.Model,
+		"content":     []map[string]interface{}{{"type": "text", "text": request.Model + ": " + last.Content}},
+		"stop_reason": "end_turn",
+	})
+}
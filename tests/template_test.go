@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	nodesbase "automation-chain/nodes/base"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	data := map[string]interface{}{
+		"generated_text": "Keep going",
+		"tags":           []interface{}{"go", "automation"},
+		"published_at":   "2025-07-25T20:03:48Z",
+		nodesbase.NodesKey: map[string]interface{}{
+			"text_generator": map[string]interface{}{"model_used": "gpt-4"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"variable", "💪 {{ .generated_text }}", "💪 Keep going"},
+		{"upstream node", "{{ .nodes.text_generator.model_used | upper }}", "GPT-4"},
+		{"date", `{{ .published_at | date "02/01/2006" }}`, "25/07/2025"},
+		{"truncate", "{{ .generated_text | truncate 7 }}", "Keep..."},
+		{"join", `{{ .tags | join ", " }}`, "go, automation"},
+		{"default", `{{ index . "topic" | default "success" }}`, "success"},
+		{"lower", "{{ .generated_text | lower }}", "keep going"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := nodesbase.RenderTemplate("test", tt.template, data)
+			if err != nil {
+				t.Fatalf("Failed to render template: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateNow(t *testing.T) {
+	result, err := nodesbase.RenderTemplate("test", `{{ now | date "2006" }}`, nil)
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if result != time.Now().Format("2006") {
+		t.Errorf("Expected current year, got %q", result)
+	}
+}
+
+func TestRenderTemplateMissingKey(t *testing.T) {
+	_, err := nodesbase.RenderTemplate("message_template", "{{ .generated_text }} {{ .date }}", map[string]interface{}{
+		"generated_text": "hello",
+	})
+	if err == nil {
+		t.Fatal("Expected an error for a missing key")
+	}
+	if !strings.Contains(err.Error(), `"date"`) || !strings.Contains(err.Error(), "message_template") {
+		t.Errorf("Expected error to name the template and the missing key, got: %v", err)
+	}
+}
+
+func TestParseTemplateSyntaxError(t *testing.T) {
+	if _, err := nodesbase.ParseTemplate("prompt_template", "{{ .topic "); err == nil {
+		t.Fatal("Expected a syntax error")
+	}
+}
+
+func TestTemplateFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected []string
+	}{
+		{"variable", "💪 {{ .generated_text }}", []string{"generated_text"}},
+		{"pipeline", "{{ .nodes.text_generator.model_used | upper }} {{ .error.message }}", []string{"nodes", "error"}},
+		{"repeated", "{{ .a }} {{ if .b }}{{ .a }}{{ else }}{{ .c }}{{ end }}", []string{"a", "b", "c"}},
+		{"range", "{{ range .tags }}{{ .name }} {{ $.topic }}{{ end }}", []string{"tags", "topic"}},
+		{"index", `{{ index . "topic" | default "success" }} {{ now | date "2006" }}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := nodesbase.ParseTemplate("test", tt.template)
+			if err != nil {
+				t.Fatalf("Failed to parse template: %v", err)
+			}
+			if fields := tmpl.Fields(); !slices.Equal(fields, tt.expected) {
+				t.Errorf("Expected fields %v, got %v", tt.expected, fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/services"
+)
+
+// slowNode sleeps for the given duration, ignoring its context
+func slowNode(id string, delay time.Duration) *fakeNode {
+	return newFakeNode(id, func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+		time.Sleep(delay)
+		return map[string]interface{}{"done": true}, nil
+	})
+}
+
+func TestNodeTimeout(t *testing.T) {
+	pipeline := pipelinebase.NewPipeline("node_timeout_pipeline")
+	pipeline.AddNodeWithOptions(slowNode("slow", time.Second), pipelinebase.NodeOptions{
+		Timeout: 20 * time.Millisecond,
+	})
+
+	start := time.Now()
+	_, err := pipeline.Execute(context.Background())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected the node to be abandoned at its timeout, took %s", time.Since(start))
+	}
+
+	var timeoutErr *pipelinebase.TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected a TimeoutError, got: %v", err)
+	}
+	if timeoutErr.Scope != pipelinebase.TimeoutScopeNode || timeoutErr.Name != "slow" {
+		t.Errorf("Expected a timeout of node slow, got %s %s", timeoutErr.Scope, timeoutErr.Name)
+	}
+	if services.ClassifyError(err) != services.ErrorClassTimeout {
+		t.Errorf("Expected timeout error class, got %s", services.ClassifyError(err))
+	}
+}
+
+func TestPipelineTimeout(t *testing.T) {
+	pipeline := pipelinebase.NewPipeline("pipeline_timeout_pipeline")
+	pipeline.AddNode(slowNode("first", 10*time.Millisecond))
+	pipeline.AddNode(slowNode("second", time.Second), "first")
+	pipeline.SetTimeout(50 * time.Millisecond)
+
+	result, err := pipeline.Execute(context.Background())
+
+	var timeoutErr *pipelinebase.TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected a TimeoutError, got: %v", err)
+	}
+	if timeoutErr.Scope != pipelinebase.TimeoutScopePipeline {
+		t.Errorf("Expected a pipeline timeout, got %s", timeoutErr.Scope)
+	}
+	if _, exists := result.Outputs["first"]; !exists {
+		t.Error("Expected the output of the node that completed before the timeout")
+	}
+}
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"automation-chain/cli"
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+	"automation-chain/services"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPriceTable(t *testing.T) {
+	prices := services.DefaultPrices
+	tests := []struct {
+		model string
+		want  services.Price
+	}{
+		{"gpt-4o", prices["gpt-4o"]},
+		{"gpt-4o-2024-08-06", prices["gpt-4o"]},
+		{"gpt-4o-mini-2024-07-18", prices["gpt-4o-mini"]},
+		{"claude-3-5-haiku-latest", prices["claude-3-5-haiku"]},
+	}
+	for _, tt := range tests {
+		if price, exists := prices.Lookup(tt.model); !exists || price != tt.want {
+			t.Errorf("Expected %s to cost %+v, got %+v", tt.model, tt.want, price)
+		}
+	}
+	if _, priced := prices.Cost("llama3", services.Usage{PromptTokens: 10}); priced {
+		t.Error("Expected local models to have no price")
+	}
+
+	path := filepath.Join(t.TempDir(), "prices.json")
+	os.WriteFile(path, []byte(`{"gpt-4o": {"prompt": 5, "completion": 15}, "llama3": {"prompt": 0, "completion": 0}}`), 0o600)
+	loaded, err := services.LoadPrices(path)
+	if err != nil {
+		t.Fatalf("Failed to load prices: %v", err)
+	}
+	if cost, priced := loaded.Cost("gpt-4o", services.Usage{PromptTokens: 1000, CompletionTokens: 100}); !priced || !closeTo(cost, 0.0065) {
+		t.Errorf("Expected the file to override gpt-4o, got %v", cost)
+	}
+	if _, priced := loaded.Cost("llama3", services.Usage{}); !priced {
+		t.Error("Expected the file to add llama3")
+	}
+	if _, exists := loaded.Lookup("claude-3-opus"); !exists {
+		t.Error("Expected the defaults to remain")
+	}
+
+	os.WriteFile(path, []byte(`{"gpt-4o": {"prompt": -1}}`), 0o600)
+	if _, err := services.LoadPrices(path); err == nil {
+		t.Error("Expected a negative price to be rejected")
+	}
+	if _, err := services.LoadPrices(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("Expected a missing file to leave the defaults, got %v", err)
+	}
+}
+
+// usagePipeline builds a text generator publishing to two channels
+func usagePipeline(t *testing.T, baseURL string, store *pipelinebase.RunStore) *pipelinebase.Pipeline {
+	t.Helper()
+
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai": map[string]interface{}{"default": map[string]interface{}{"api_key": "local", "base_url": baseURL, "model": "gpt-4o"}},
+	})
+	pipeline, err := pipelinebase.NewPipelineBuilder(credentials).BuildPipeline("usage_pipeline", []nodesbase.NodeDefinition{
+		{ID: "generator", Type: "text_generator", Config: map[string]interface{}{"prompt_template": "Motivate me"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+
+	for _, channel := range []string{"@news", "@personal"} {
+		channel := channel
+		pipeline.AddNode(newFakeNode(channel, func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"published": true, "channel_id": channel}, nil
+		}), "generator")
+	}
+	pipeline.SetRunStore(store)
+	return pipeline
+}
+
+func TestUsageAccounting(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Model: "gpt-4o-2024-08-06", PromptTokens: 1000, CompletionTokens: 500})
+	dir := t.TempDir()
+	store := pipelinebase.NewRunStore(dir)
+
+	result, err := usagePipeline(t, server.URL, store).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	// 1000 prompt tokens at $2.5/M and 500 completion tokens at $10/M
+	usage, _ := nodesbase.UsageOf(result.Outputs["generator"])
+	if usage.TotalTokens != 1500 || !closeTo(usage.Cost, 0.0075) || usage.Model != "gpt-4o-2024-08-06" || usage.Credential != "openai/default" {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+	if tokens, _ := result.Output("generator", "tokens_used"); tokens != 1500 {
+		t.Errorf("Expected 1500 tokens used, got %v", tokens)
+	}
+
+	run, err := store.Load(result.RunID)
+	if err != nil {
+		t.Fatalf("Failed to load run: %v", err)
+	}
+	if run.Usage == nil || run.Usage.PromptTokens != 1000 || !closeTo(run.Usage.Cost, 0.0075) {
+		t.Errorf("Expected the run to record its usage, got %+v", run.Usage)
+	}
+
+	// A second run; the ledger holds one record per run
+	if _, err := usagePipeline(t, server.URL, store).Execute(context.Background()); err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	records, err := store.UsageRecords()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 usage records, got %d (%v)", len(records), err)
+	}
+	if records[0].Pipeline != "usage_pipeline" || records[0].Node != "generator" || len(records[0].Channels) != 2 {
+		t.Errorf("Unexpected record %+v", records[0])
+	}
+
+	// Each channel is charged half of what the runs cost
+	report, err := pipelinebase.SummarizeUsage(records, pipelinebase.UsageByChannel)
+	if err != nil {
+		t.Fatalf("Failed to summarize usage: %v", err)
+	}
+	if len(report) != 2 || report[0].Group != "@news" || report[0].Calls != 2 || report[0].TotalTokens != 1500 || !closeTo(report[0].Cost, 0.0075) {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if _, err := pipelinebase.SummarizeUsage(records, "weekday"); err == nil {
+		t.Error("Expected an unknown grouping to be rejected")
+	}
+
+	if code := cli.Run([]string{"usage", "-runs-dir", dir, "-by", "pipeline"}); code != 0 {
+		t.Errorf("Expected the usage report to succeed, got exit code %d", code)
+	}
+	if code := cli.Run([]string{"usage", "-runs-dir", dir, "-month", "May"}); code != 1 {
+		t.Errorf("Expected an invalid month to fail, got exit code %d", code)
+	}
+}
+
+func TestUnpricedModel(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{Model: "gpt-4o-2024-08-06", PromptTokens: 100, CompletionTokens: 50})
+	services.SetPrices(services.PriceTable{"gpt-3.5-turbo": {Prompt: 0.5, Completion: 1.5}})
+	defer services.SetPrices(services.DefaultPrices)
+
+	result, err := usagePipeline(t, server.URL, pipelinebase.NewRunStore(t.TempDir())).Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if usage := result.TotalUsage(); !usage.Unpriced || usage.Cost != 0 || usage.TotalTokens != 150 {
+		t.Errorf("Expected the tokens of an unpriced model without a cost, got %+v", usage)
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"automation-chain/config"
+	nodesbase "automation-chain/nodes/base"
+	pipelinebase "automation-chain/pipelines/base"
+)
+
+func init() {
+	nodesbase.RegisterNodeType(nodesbase.NodeType{
+		Type:    "test_writer",
+		Outputs: []nodesbase.PortSpec{{Name: "generated_text", Type: nodesbase.ParamString}},
+		Factory: func(config nodesbase.NodeConfig) (nodesbase.Node, error) {
+			return &fakeNode{config: config, execute: succeeding}, nil
+		},
+	})
+	nodesbase.RegisterNodeType(nodesbase.NodeType{
+		Type:    "test_poster",
+		Inputs:  []nodesbase.PortSpec{{Name: "generated_text", Type: nodesbase.ParamString}},
+		Outputs: []nodesbase.PortSpec{{Name: "published", Type: nodesbase.ParamBoolean}},
+		Factory: func(config nodesbase.NodeConfig) (nodesbase.Node, error) {
+			if _, ok := config.Parameters["channel"].(string); !ok {
+				return nil, fmt.Errorf("channel is required")
+			}
+			return &fakeNode{config: config, execute: succeeding}, nil
+		},
+	})
+}
+
+func TestBuilderReportsEveryProblem(t *testing.T) {
+	builder := pipelinebase.NewPipelineBuilder(config.NewCredentialsManager())
+
+	_, err := builder.BuildPipeline("invalid_pipeline", []nodesbase.NodeDefinition{
+		{ID: "writer", Type: "test_writer", Name: "Writer"},
+		{ID: "first", Type: "test_poster", Name: "First", Config: map[string]interface{}{"channel": "@first"}},
+		{ID: "second", Type: "test_poster", Name: "Second", DependsOn: []string{"writer"}},
+		{ID: "third", Type: "test_poster", Name: "Third", DependsOn: []string{"first"}, Config: map[string]interface{}{"channel": "@third"}},
+		{ID: "unknown", Type: "does_not_exist", Name: "Unknown", DependsOn: []string{"missing"}},
+	})
+	if err == nil {
+		t.Fatal("Expected the pipeline to be rejected")
+	}
+	var validationErr *pipelinebase.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %T: %v", err, err)
+	}
+
+	expected := []string{
+		"node first: test_poster expects \"generated_text\" but has no upstream node producing it",
+		"node second: channel is required",
+		"node third: test_poster expects \"generated_text\" but none of the upstream nodes (first) produces it",
+		"node unknown: unknown node type: does_not_exist",
+		"node unknown depends on unknown node missing",
+	}
+	if len(validationErr.Problems) != len(expected) {
+		t.Fatalf("Expected %d problems, got %d: %v", len(expected), len(validationErr.Problems), err)
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected problem %q in:\n%v", problem, err)
+		}
+	}
+}
+
+func TestContractChecksErrorHandlers(t *testing.T) {
+	builder := pipelinebase.NewPipelineBuilder(config.NewCredentialsManager())
+
+	_, err := builder.BuildPipeline("handler_pipeline", []nodesbase.NodeDefinition{
+		{ID: "first", Type: "test_poster", Name: "First", OnError: nodesbase.OnErrorRoute, ErrorHandler: "notify", Config: map[string]interface{}{"channel": "@first"}},
+		{ID: "notify", Type: "test_poster", Name: "Notify", Config: map[string]interface{}{"channel": "@admin"}},
+	})
+	if err == nil {
+		t.Fatal("Expected the missing inputs to be reported")
+	}
+	if !strings.Contains(err.Error(), "node first: test_poster expects \"generated_text\" but has no upstream node producing it") {
+		t.Errorf("Expected the routed node problem, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "node notify: test_poster expects \"generated_text\" when handling errors of first") {
+		t.Errorf("Expected the error handler problem, got: %v", err)
+	}
+
+	pipeline, err := builder.BuildPipeline("valid_pipeline", []nodesbase.NodeDefinition{
+		{ID: "writer", Type: "test_writer", Name: "Writer"},
+		{ID: "first", Type: "test_poster", Name: "First", Config: map[string]interface{}{"channel": "@first"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected the sequential pipeline to be valid, got: %v", err)
+	}
+	if _, err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatalf("Pipeline execution failed: %v", err)
+	}
+}
+
+func TestContractChecksBuiltInNodes(t *testing.T) {
+	server := newChatStandIn(t, chatStandIn{})
+	credentials := config.NewCredentialsManager()
+	credentials.SetCredentials(map[string]interface{}{
+		"openai": map[string]interface{}{"default": map[string]interface{}{"api_key": "local", "base_url": server.URL}},
+		"telegram": map[string]interface{}{
+			"news":     map[string]interface{}{"token": checkBotToken, "channel_id": "@news"},
+			"personal": map[string]interface{}{"token": checkBotToken, "channel_id": "@personal"},
+		},
+	})
+	builder := pipelinebase.NewPipelineBuilder(credentials)
+
+	// The default message template publishes the generated text
+	_, err := builder.BuildPipeline("publisher_pipeline", []nodesbase.NodeDefinition{
+		{ID: "news", Type: "telegram_publisher", Name: "News", Credentials: "news"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "node news: telegram_publisher expects \"generated_text\" but has no upstream node producing it") {
+		t.Errorf("Expected the missing generated text to be reported, got: %v", err)
+	}
+
+	pipeline, err := builder.BuildPipeline("sequential_pipeline", []nodesbase.NodeDefinition{
+		{ID: "gen", Type: "text_generator", Name: "Generate", Config: map[string]interface{}{"prompt_template": "Write the news"}},
+		{ID: "news", Type: "telegram_publisher", Name: "News", Credentials: "news"},
+		{ID: "personal", Type: "telegram_publisher", Name: "Personal", Credentials: "personal"},
+	})
+	if err != nil {
+		t.Fatalf("Expected the sequential pipeline to be valid, got: %v", err)
+	}
+	result, err := pipeline.Execute(nodesbase.WithDryRun(context.Background()))
+	if err != nil {
+		t.Fatalf("Pipeline execution failed: %v", err)
+	}
+	for _, id := range []string{"news", "personal"} {
+		if message, _ := result.Output(id, "message"); !strings.Contains(fmt.Sprint(message), "served /chat/completions") {
+			t.Errorf("Expected %s to publish the generated text, got %q", id, message)
+		}
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+
+	"automation-chain/config"
 )
 
 // Update represents a Telegram update
@@ -48,9 +50,12 @@ func main() {
 		fmt.Println("Example: go run get_channel_id.go -token 1234567890:ABCdefGHIjklMNOpqrsTUVwxyz")
 		os.Exit(1)
 	}
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates", *botToken)
+	// The token is part of the request URL, which errors echo
+	redactor := config.Secrets()
+	redactor.Add(*botToken)
+	log.SetOutput(redactor.Writer(os.Stderr))
 
-	fmt.Println(url)
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates", *botToken)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -69,7 +74,7 @@ func main() {
 	}
 
 	if !updates.OK {
-		fmt.Printf("Error: %s\n", string(body))
+		fmt.Printf("Error: %s\n", redactor.Redact(string(body)))
 		os.Exit(1)
 	}
 